@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"nutanix-exporter/internal/nutanix"
+	"nutanix-exporter/internal/server"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runBackfill implements the backfill subcommand: it writes the historical
+// stats of the cluster, hosts and VMs of a section in the OpenMetrics format,
+// to be imported with `promtool tsdb create-blocks-from openmetrics`
+func runBackfill(args []string) int {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	configFile := fs.String("nutanix.conf", "", "Which Nutanixconf.yml file should be used")
+	section := fs.String("section", "default", "Section of the config file to backfill")
+	start := fs.String("start", "", "Start of the time range, RFC 3339 (default 24h before end)")
+	end := fs.String("end", "", "End of the time range, RFC 3339 (default now)")
+	interval := fs.Duration("interval", nutanix.BACKFILL_DEFAULT_INTERVAL, "Sampling interval requested from Prism")
+	output := fs.String("output", "-", "OpenMetrics file to write, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	endTime := time.Now()
+	if len(*end) > 0 {
+		t, err := time.Parse(time.RFC3339, *end)
+		if err != nil {
+			log.Errorf("Invalid end: %v", err)
+			return 2
+		}
+		endTime = t
+	}
+	startTime := endTime.Add(-24 * time.Hour)
+	if len(*start) > 0 {
+		t, err := time.Parse(time.RFC3339, *start)
+		if err != nil {
+			log.Errorf("Invalid start: %v", err)
+			return 2
+		}
+		startTime = t
+	}
+	if !startTime.Before(endTime) {
+		log.Errorf("Start %s is not before end %s", startTime, endTime)
+		return 2
+	}
+
+	file, err := os.ReadFile(*configFile)
+	if err != nil {
+		log.Errorf("Failed to read config file: %v", err)
+		return 1
+	}
+	config, err := server.ParseConfig(file)
+	if err != nil {
+		log.Errorf("Invalid config file: %v", err)
+		return 1
+	}
+	conf, ok := config[*section]
+	if !ok {
+		log.Errorf("Section '%s' not found in config", *section)
+		return 1
+	}
+
+	nutanixAPI := nutanix.NewNutanix(conf.Host, conf.Username, conf.Password, conf.MaxParallelRequests).WithRecording(conf.Recording)
+	backfill := nutanix.NewBackfill(nutanixAPI, *interval)
+	checkCollect := func(f string) bool {
+		val, exist := conf.Collect[f]
+		return !exist || val
+	}
+	if checkCollect("cluster") {
+		clusterCollector := nutanix.NewClusterCollector(nutanixAPI)
+		clusterCollector.Configure(conf.Collectors["cluster"])
+		clusterCollector.SetMetricNaming(conf.MetricNaming)
+		backfill.WithCluster(clusterCollector)
+	}
+	if checkCollect("hosts") {
+		hostsCollector := nutanix.NewHostsCollector(nutanixAPI, false, nil)
+		hostsCollector.SetFilter(conf.Filters["hosts"])
+		hostsCollector.Configure(conf.Collectors["hosts"])
+		hostsCollector.SetMetricNaming(conf.MetricNaming)
+		backfill.WithHosts(hostsCollector)
+	}
+	if checkCollect("vms") {
+		vmsCollector := nutanix.NewVmsCollector(nutanixAPI, false)
+		vmsCollector.SetFilter(conf.Filters["vms"])
+		vmsCollector.Configure(conf.Collectors["vms"])
+		vmsCollector.SetMetricNaming(conf.MetricNaming)
+		if conf.Collect["vm_categories"] && len(conf.VmCategories.Labels) > 0 {
+			categories := nutanix.NewVmCategories(server.PrismCentralClient(conf, server.NewClient), nutanixAPI, conf.VmCategories.TTL)
+			vmsCollector.WithCategoryLabels(categories, conf.VmCategories.Labels)
+		}
+		backfill.WithVms(vmsCollector)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Errorf("Failed to create output file: %v", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	log.Infof("Backfilling section %s from %s to %s", *section, startTime.Format(time.RFC3339), endTime.Format(time.RFC3339))
+	if err := backfill.Write(w, startTime, endTime); err != nil {
+		log.Errorf("Backfill failed: %v", err)
+		return 1
+	}
+	return 0
+}
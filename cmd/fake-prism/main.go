@@ -0,0 +1,41 @@
+// fake-prism serves a simulated Prism Element API, to run the exporter
+// against clusters of any size without access to one.
+package main
+
+import (
+	"flag"
+	"net/http"
+	"nutanix-exporter/internal/fakeprism"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	conf := fakeprism.DefaultConfig()
+	listenAddress := flag.String("listen-address", ":9440", "The address to listen on for HTTP requests.")
+	flag.IntVar(&conf.Hosts, "hosts", conf.Hosts, "Number of hosts")
+	flag.IntVar(&conf.VMs, "vms", conf.VMs, "Number of guest VMs")
+	flag.IntVar(&conf.NICsPerEntity, "nics", conf.NICsPerEntity, "Number of NICs per host and VM")
+	flag.IntVar(&conf.VDisksPerVM, "vdisks", conf.VDisksPerVM, "Number of virtual disks per VM")
+	flag.IntVar(&conf.StorageContainers, "containers", conf.StorageContainers, "Number of storage containers")
+	flag.IntVar(&conf.Snapshots, "snapshots", conf.Snapshots, "Number of VM snapshots")
+	flag.DurationVar(&conf.Latency, "latency", conf.Latency, "Latency added to every response")
+	flag.Float64Var(&conf.ErrorRate, "error-rate", conf.ErrorRate, "Fraction of requests answered with a server error")
+	failPaths := flag.String("fail-paths", "", "Comma separated API paths always answered with a server error, e.g. v2.0/snapshots")
+	flag.Int64Var(&conf.Seed, "seed", conf.Seed, "Seed of the injected errors")
+	flag.Parse()
+
+	for _, path := range strings.Split(*failPaths, ",") {
+		if path = strings.TrimSpace(path); len(path) > 0 {
+			conf.FailPaths = append(conf.FailPaths, path)
+		}
+	}
+
+	server := fakeprism.New(conf)
+	log.Infof("Fake cluster %s with %d hosts and %d VMs", server.ClusterUUID(), conf.Hosts, conf.VMs)
+	log.Infof("Starting Server: %s", *listenAddress)
+	if err := http.ListenAndServe(*listenAddress, server); err != nil {
+		log.Fatal(err)
+	}
+}
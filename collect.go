@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"nutanix-exporter/internal/nutanix"
+	"nutanix-exporter/internal/server"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// jsonFamily is a metric family of the json output of the collect subcommand
+type jsonFamily struct {
+	Name    string       `json:"name"`
+	Help    string       `json:"help"`
+	Type    string       `json:"type"`
+	Metrics []jsonMetric `json:"metrics"`
+}
+
+type jsonMetric struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// runCollect implements the collect subcommand: it runs the collectors of a
+// section once, like a scrape of /metrics?section=X, and prints the metrics
+// to stdout
+func runCollect(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("collect", flag.ContinueOnError)
+	configFile := fs.String("nutanix.conf", "", "Which Nutanixconf.yml file should be used")
+	section := fs.String("section", "default", "Section of the config file to collect")
+	collectors := fs.String("collector", "", "Comma separated collectors to run (default the collectors enabled for the section)")
+	format := fs.String("format", "text", "Output format: text, openmetrics or json")
+	replay := fs.String("replay", "", "Replay the Prism responses recorded in the directory instead of calling Prism")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	switch *format {
+	case "text", "openmetrics", "json":
+	default:
+		log.Errorf("Unknown format %s", *format)
+		return 2
+	}
+
+	file, err := os.ReadFile(*configFile)
+	if err != nil {
+		log.Errorf("Failed to read config file: %v", err)
+		return 1
+	}
+	config, err := server.ParseConfig(file)
+	if err != nil {
+		log.Errorf("Invalid config file: %v", err)
+		return 1
+	}
+	conf, ok := config[*section]
+	if !ok {
+		log.Errorf("Section '%s' not found in config", *section)
+		return 1
+	}
+
+	known := make(map[string]bool)
+	for _, name := range server.CollectorNames {
+		known[name] = true
+	}
+	only := make(map[string]bool)
+	for _, name := range strings.Split(*collectors, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			if !known[name] {
+				log.Errorf("Unknown collector %s, valid collectors: %s", name, strings.Join(server.CollectorNames, ", "))
+				return 1
+			}
+			only[name] = true
+		}
+	}
+
+	if len(*replay) > 0 {
+		conf.Recording = &nutanix.Recording{Mode: nutanix.RECORDING_MODE_REPLAY, Dir: *replay}
+	}
+	gatherer, failed := server.SectionGatherer(context.Background(), prometheus.NewRegistry(), conf, server.NewClient, server.SectionLogger(log.StandardLogger(), conf), only)
+	families, err := gatherer.Gather()
+	if err != nil {
+		log.Errorf("Failed to gather metrics: %v", err)
+	}
+	if err := writeFamilies(stdout, families, *format); err != nil {
+		log.Errorf("Failed to write metrics: %v", err)
+		return 1
+	}
+
+	if names := failed(); len(names) > 0 {
+		log.Errorf("Collectors failed: %s", strings.Join(names, ", "))
+		return 1
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// writeFamilies writes the metric families in the given format
+func writeFamilies(w io.Writer, families []*dto.MetricFamily, format string) error {
+	if format == "json" {
+		out := make([]jsonFamily, 0, len(families))
+		for _, family := range families {
+			f := jsonFamily{
+				Name:    family.GetName(),
+				Help:    family.GetHelp(),
+				Type:    strings.ToLower(family.GetType().String()),
+				Metrics: []jsonMetric{},
+			}
+			for _, m := range family.Metric {
+				labels := make(map[string]string, len(m.Label))
+				for _, l := range m.Label {
+					labels[l.GetName()] = l.GetValue()
+				}
+				var value float64
+				switch {
+				case m.Gauge != nil:
+					value = m.Gauge.GetValue()
+				case m.Counter != nil:
+					value = m.Counter.GetValue()
+				case m.Untyped != nil:
+					value = m.Untyped.GetValue()
+				}
+				f.Metrics = append(f.Metrics, jsonMetric{Labels: labels, Value: value})
+			}
+			out = append(out, f)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	expFormat := expfmt.FmtText
+	if format == "openmetrics" {
+		expFormat = expfmt.FmtOpenMetrics_1_0_0
+	}
+	enc := expfmt.NewEncoder(w, expFormat)
+	for _, family := range families {
+		if err := enc.Encode(family); err != nil {
+			return err
+		}
+	}
+	if closer, ok := enc.(expfmt.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// usage prints the subcommands next to the flags of the exporter
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n       %s backfill [flags]\n       %s collect [flags]\n\nFlags:\n", os.Args[0], os.Args[0], os.Args[0])
+	flag.PrintDefaults()
+}
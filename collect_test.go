@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"nutanix-exporter/internal/fakeprism"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectConfig starts a fake Prism and writes a config file with a section
+// pointing to it, returns the path of the config file
+func collectConfig(t *testing.T, conf fakeprism.Config) string {
+	prism := httptest.NewServer(fakeprism.New(conf))
+	t.Cleanup(prism.Close)
+	config := fmt.Sprintf("e2e:\n  nutanix_host: %s\n  nutanix_user: admin\n  nutanix_password: secret\n", prism.URL)
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+	return path
+}
+
+// collect runs the collect subcommand and returns the exit code and output
+func collect(t *testing.T, args ...string) (int, string) {
+	var out bytes.Buffer
+	code := runCollect(args, &out)
+	return code, out.String()
+}
+
+func TestCollectFormats(t *testing.T) {
+	config := collectConfig(t, fakeprism.DefaultConfig())
+	args := []string{"-nutanix.conf", config, "-section", "e2e", "-collector", "hosts"}
+
+	code, out := collect(t, append(args, "-format", "text")...)
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "# TYPE nutanix_hosts_hypervisor_cpu_usage_ppm gauge")
+	assert.NotContains(t, out, "# EOF")
+
+	code, out = collect(t, append(args, "-format", "openmetrics")...)
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "# TYPE nutanix_hosts_hypervisor_cpu_usage_ppm gauge")
+	assert.True(t, strings.HasSuffix(out, "# EOF\n"))
+
+	code, out = collect(t, append(args, "-format", "json")...)
+	require.Equal(t, 0, code)
+	var families []jsonFamily
+	require.NoError(t, json.Unmarshal([]byte(out), &families))
+	var cpu *jsonFamily
+	for i := range families {
+		if families[i].Name == "nutanix_hosts_hypervisor_cpu_usage_ppm" {
+			cpu = &families[i]
+		}
+	}
+	require.NotNil(t, cpu)
+	assert.Equal(t, "gauge", cpu.Type)
+	assert.Len(t, cpu.Metrics, 3)
+	assert.NotEmpty(t, cpu.Metrics[0].Labels["uuid"])
+
+	code, _ = collect(t, append(args, "-format", "xml")...)
+	assert.Equal(t, 2, code)
+}
+
+func TestCollectCollectorSelection(t *testing.T) {
+	config := collectConfig(t, fakeprism.DefaultConfig())
+
+	// Only the listed collectors run
+	code, out := collect(t, "-nutanix.conf", config, "-section", "e2e", "-collector", "hosts, snapshots")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "nutanix_hosts_")
+	assert.Contains(t, out, "nutanix_snapshots_")
+	assert.NotContains(t, out, "nutanix_vms_")
+	assert.NotContains(t, out, "nutanix_cluster_")
+
+	// Without selection, the collectors enabled for the section run
+	code, out = collect(t, "-nutanix.conf", config, "-section", "e2e")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "nutanix_vms_")
+	assert.Contains(t, out, "nutanix_cluster_")
+	assert.Contains(t, out, "nutanix_snapshots_")
+}
+
+func TestCollectUnknownCollector(t *testing.T) {
+	config := collectConfig(t, fakeprism.DefaultConfig())
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	code, out := collect(t, "-nutanix.conf", config, "-section", "e2e", "-collector", "hosts,vm")
+	assert.Equal(t, 1, code)
+	assert.Empty(t, out)
+	assert.Contains(t, logs.String(), "Unknown collector vm, valid collectors: storage_containers, vm_categories, vms, hosts")
+}
+
+func TestCollectFailedCollector(t *testing.T) {
+	conf := fakeprism.DefaultConfig()
+	conf.FailPaths = []string{"v2.0/snapshots"}
+	config := collectConfig(t, conf)
+
+	code, out := collect(t, "-nutanix.conf", config, "-section", "e2e", "-collector", "hosts,snapshots")
+	assert.Equal(t, 1, code)
+	// The metrics of the other collectors are printed anyway
+	assert.Contains(t, out, "nutanix_hosts_hypervisor_cpu_usage_ppm")
+
+	code, _ = collect(t, "-nutanix.conf", config, "-section", "e2e", "-collector", "hosts")
+	assert.Equal(t, 0, code)
+
+	code, _ = collect(t, "-nutanix.conf", config, "-section", "unknown")
+	assert.Equal(t, 1, code)
+}
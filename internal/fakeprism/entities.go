@@ -0,0 +1,330 @@
+package fakeprism
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	KIND_CLUSTER = iota + 1
+	KIND_HOST
+	KIND_VM
+	KIND_NIC
+	KIND_VDISK
+	KIND_CONTAINER
+	KIND_SNAPSHOT
+)
+
+const GIB = 1024 * 1024 * 1024
+
+// cluster holds the entities of the simulated cluster. Entities are built
+// once and only read afterwards.
+type cluster struct {
+	uuid       string
+	entity     map[string]interface{}
+	hosts      []map[string]interface{}
+	vmsV1      []map[string]interface{}
+	vmsV2      []map[string]interface{}
+	vmNics     map[string][]map[string]interface{} // vm uuid -> NICs
+	hostNics   map[string][]map[string]interface{} // host uuid -> NICs
+	vdisks     []map[string]interface{}
+	containers []map[string]interface{}
+	snapshots  []map[string]interface{}
+}
+
+// entityUUID returns a stable uuid per kind and index
+func entityUUID(kind, i int) string {
+	return fmt.Sprintf("%08x-0000-4000-8000-%012x", kind, i)
+}
+
+// ioStats returns controller stats as reported by Prism, values are strings
+func ioStats(i int) map[string]interface{} {
+	return map[string]interface{}{
+		"controller_total_read_io_size_kbytes":  strconv.Itoa(1000 + i*10),
+		"controller_total_io_size_kbytes":       strconv.Itoa(3000 + i*10),
+		"controller_num_read_io":                strconv.Itoa(100 + i),
+		"controller_num_write_io":               strconv.Itoa(200 + i),
+		"controller_avg_read_io_latency_usecs":  strconv.Itoa(500 + i),
+		"controller_avg_write_io_latency_usecs": strconv.Itoa(800 + i),
+		"controller_user_bytes":                 strconv.Itoa((i + 1) * GIB),
+	}
+}
+
+func networkStats(i int) map[string]interface{} {
+	return map[string]interface{}{
+		"network.received_bytes":           strconv.Itoa(1000000 + i),
+		"network.received_pkts":            strconv.Itoa(1000 + i),
+		"network.error_received_pkts":      "0",
+		"network.transmitted_bytes":        strconv.Itoa(2000000 + i),
+		"network.transmitted_pkts":         strconv.Itoa(2000 + i),
+		"network.error_transmitted_pkts":   "0",
+		"network.dropped_received_pkts":    "0",
+		"network.dropped_transmitted_pkts": "0",
+	}
+}
+
+func newCluster(conf Config) *cluster {
+	c := &cluster{
+		uuid:     entityUUID(KIND_CLUSTER, 0),
+		vmNics:   make(map[string][]map[string]interface{}),
+		hostNics: make(map[string][]map[string]interface{}),
+	}
+	nic := 0
+
+	for i := 0; i < conf.StorageContainers; i++ {
+		stats := ioStats(i)
+		c.containers = append(c.containers, map[string]interface{}{
+			"storage_container_uuid": entityUUID(KIND_CONTAINER, i),
+			"id":                     fmt.Sprintf("%s::%d", c.uuid, 1000+i),
+			"cluster_uuid":           c.uuid,
+			"name":                   fmt.Sprintf("container%02d", i+1),
+			"replication_factor":     2,
+			"compression_enabled":    i%2 == 0,
+			"max_capacity":           10 * 1024 * GIB,
+			"stats":                  stats,
+			"usage_stats": map[string]interface{}{
+				"storage.usage_bytes":                       strconv.Itoa((i + 1) * 100 * GIB),
+				"storage.capacity_bytes":                    strconv.Itoa(10 * 1024 * GIB),
+				"storage.logical_usage_bytes":               strconv.Itoa((i + 1) * 50 * GIB),
+				"storage.container_reserved_capacity_bytes": "0",
+			},
+		})
+	}
+
+	for i := 0; i < conf.Hosts; i++ {
+		uuid := entityUUID(KIND_HOST, i)
+		stats := ioStats(i)
+		stats["hypervisor_cpu_usage_ppm"] = strconv.Itoa(100000 + i*50000)
+		stats["hypervisor_memory_usage_ppm"] = strconv.Itoa(400000 + i*10000)
+		stats["hypervisor_num_received_bytes"] = strconv.Itoa(1000000 * (i + 1))
+		stats["hypervisor_num_transmitted_bytes"] = strconv.Itoa(2000000 * (i + 1))
+		c.hosts = append(c.hosts, map[string]interface{}{
+			"uuid":                     uuid,
+			"cluster_uuid":             c.uuid,
+			"name":                     fmt.Sprintf("host%02d", i+1),
+			"host_type":                "HYPER_CONVERGED",
+			"hypervisor_address":       fmt.Sprintf("10.0.0.%d", 10+i),
+			"hypervisor_full_name":     "Nutanix 20230302.100187",
+			"serial":                   fmt.Sprintf("SN%06d", i),
+			"block_model_name":         "NX-3060-G8",
+			"service_vmid":             fmt.Sprintf("%s::%d", c.uuid, 2+i),
+			"state":                    "NORMAL",
+			"host_in_maintenance_mode": false,
+			"is_degraded":              false,
+			"num_vms":                  0,
+			"num_cpu_cores":            16,
+			"num_cpu_sockets":          2,
+			"num_cpu_threads":          32,
+			"cpu_frequency_in_hz":      2400000000,
+			"cpu_capacity_in_hz":       38400000000,
+			"memory_capacity_in_bytes": 512 * GIB,
+			"boot_time_in_usecs":       time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).UnixMicro(),
+			"stats":                    stats,
+			"usage_stats": map[string]interface{}{
+				"storage.capacity_bytes":      strconv.Itoa(10 * 1024 * GIB),
+				"storage.usage_bytes":         strconv.Itoa((i + 1) * 200 * GIB),
+				"storage.logical_usage_bytes": strconv.Itoa((i + 1) * 100 * GIB),
+			},
+		})
+		for n := 0; n < conf.NICsPerEntity; n++ {
+			c.hostNics[uuid] = append(c.hostNics[uuid], map[string]interface{}{
+				"uuid":               entityUUID(KIND_NIC, nic),
+				"node_uuid":          uuid,
+				"hostname":           fmt.Sprintf("host%02d", i+1),
+				"name":               fmt.Sprintf("eth%d", n),
+				"mac_address":        fmt.Sprintf("0c:c4:7a:00:%02x:%02x", i, n),
+				"ipv4_addresses":     []interface{}{fmt.Sprintf("10.0.0.%d", 10+i)},
+				"mtu_in_bytes":       1500,
+				"link_speed_in_kbps": 10000000,
+				"stats":              networkStats(nic),
+			})
+			nic++
+		}
+
+		// Controller VM of the host
+		c.vmsV1 = append(c.vmsV1, map[string]interface{}{
+			"uuid":                  entityUUID(KIND_VM, 100000+i),
+			"vmName":                fmt.Sprintf("NTNX-%s-CVM", strings.ToUpper(fmt.Sprintf("SN%06d", i))),
+			"hostUuid":              uuid,
+			"clusterUuid":           c.uuid,
+			"powerState":            "on",
+			"controllerVm":          true,
+			"numVCpus":              12,
+			"memoryCapacityInBytes": 32 * GIB,
+			"ipAddresses":           []interface{}{fmt.Sprintf("10.0.1.%d", 10+i)},
+			"stats": map[string]interface{}{
+				"hypervisor_cpu_usage_ppm": "250000",
+			},
+		})
+	}
+
+	vdisk := 0
+	for i := 0; i < conf.VMs; i++ {
+		uuid := entityUUID(KIND_VM, i)
+		name := fmt.Sprintf("vm%04d", i+1)
+		hostUUID := ""
+		powerState := "off"
+		if conf.Hosts > 0 && i%10 != 9 {
+			hostUUID = entityUUID(KIND_HOST, i%conf.Hosts)
+			powerState = "on"
+			c.hosts[i%conf.Hosts]["num_vms"] = c.hosts[i%conf.Hosts]["num_vms"].(int) + 1
+		}
+		c.vmsV1 = append(c.vmsV1, map[string]interface{}{
+			"uuid":                          uuid,
+			"vmName":                        name,
+			"hostUuid":                      hostUUID,
+			"clusterUuid":                   c.uuid,
+			"powerState":                    powerState,
+			"controllerVm":                  false,
+			"numVCpus":                      2 + i%4,
+			"memoryCapacityInBytes":         (4 + i%4*4) * GIB,
+			"memoryReservedCapacityInBytes": 0,
+			"cpuReservedInHz":               0,
+			"diskCapacityInBytes":           conf.VDisksPerVM * 50 * GIB,
+			"ipAddresses":                   []interface{}{fmt.Sprintf("10.1.%d.%d", i/250, i%250+1)},
+			"stats": map[string]interface{}{
+				"hypervisor_cpu_usage_ppm":         strconv.Itoa(10000 * (i%100 + 1)),
+				"hypervisor_num_received_bytes":    strconv.Itoa(1000 * (i + 1)),
+				"hypervisor_num_transmitted_bytes": strconv.Itoa(2000 * (i + 1)),
+				"hypervisor.cpu_ready_time_ppm":    strconv.Itoa(i % 1000),
+				"hypervisor_memory_usage_ppm":      "500000",
+				"guest.memory_usage_bytes":         strconv.Itoa(2 * GIB),
+				"controller_timespan_usecs":        "30000000",
+			},
+		})
+		c.vmsV2 = append(c.vmsV2, map[string]interface{}{
+			"uuid":                 uuid,
+			"name":                 name,
+			"host_uuid":            hostUUID,
+			"power_state":          powerState,
+			"num_vcpus":            2 + i%4,
+			"memory_mb":            (4 + i%4*4) * 1024,
+			"ha_priority":          0,
+			"is_agent_vm":          false,
+			"vm_features":          map[string]interface{}{},
+			"vm_logical_timestamp": i + 1,
+		})
+		for n := 0; n < conf.NICsPerEntity; n++ {
+			c.vmNics[uuid] = append(c.vmNics[uuid], map[string]interface{}{
+				"uuid":          entityUUID(KIND_NIC, nic),
+				"vmUuid":        uuid,
+				"vmName":        name,
+				"name":          fmt.Sprintf("nic%d", n),
+				"macAddress":    fmt.Sprintf("50:6b:8d:%02x:%02x:%02x", i/256, i%256, n),
+				"ipv4Addresses": []interface{}{fmt.Sprintf("10.1.%d.%d", i/250, i%250+1)},
+				"mtuInBytes":    1500,
+				"stats":         networkStats(nic),
+			})
+			nic++
+		}
+		for d := 0; d < conf.VDisksPerVM; d++ {
+			container := ""
+			if conf.StorageContainers > 0 {
+				container = entityUUID(KIND_CONTAINER, vdisk%conf.StorageContainers)
+			}
+			c.vdisks = append(c.vdisks, map[string]interface{}{
+				"uuid":                   entityUUID(KIND_VDISK, vdisk),
+				"cluster_uuid":           c.uuid,
+				"storage_container_uuid": container,
+				"attached_vm_uuid":       uuid,
+				"attached_vmname":        name,
+				"disk_address":           fmt.Sprintf("scsi.%d", d),
+				"disk_capacity_in_bytes": 50 * GIB,
+				"stats":                  ioStats(vdisk),
+			})
+			vdisk++
+		}
+	}
+
+	for i := 0; i < conf.Snapshots && conf.VMs > 0; i++ {
+		vm := c.vmsV2[i%conf.VMs]
+		c.snapshots = append(c.snapshots, map[string]interface{}{
+			"uuid":          entityUUID(KIND_SNAPSHOT, i),
+			"snapshot_name": fmt.Sprintf("snap%03d", i+1),
+			"vm_uuid":       vm["uuid"],
+			"created_time":  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Hour).UnixMicro(),
+			"vm_create_spec": map[string]interface{}{
+				"name": vm["name"],
+			},
+		})
+	}
+
+	stats := ioStats(0)
+	stats["hypervisor_cpu_usage_ppm"] = "200000"
+	stats["hypervisor_memory_usage_ppm"] = "450000"
+	stats["hypervisor_num_received_bytes"] = strconv.Itoa(1000000 * conf.Hosts)
+	stats["hypervisor_num_transmitted_bytes"] = strconv.Itoa(2000000 * conf.Hosts)
+	c.entity = map[string]interface{}{
+		"uuid":                       c.uuid,
+		"id":                         fmt.Sprintf("%s::1", c.uuid),
+		"name":                       "fake-cluster",
+		"cluster_external_ipaddress": "10.0.0.1",
+		"version":                    "6.5.5",
+		"num_nodes":                  conf.Hosts,
+		"stats":                      stats,
+		"usage_stats": map[string]interface{}{
+			"storage.capacity_bytes":      strconv.Itoa(conf.Hosts * 10 * 1024 * GIB),
+			"storage.usage_bytes":         strconv.Itoa(conf.Hosts * 200 * GIB),
+			"storage.logical_usage_bytes": strconv.Itoa(conf.Hosts * 100 * GIB),
+			"cpu_capacity_in_hz":          strconv.Itoa(conf.Hosts * 38400000000),
+		},
+	}
+	return c
+}
+
+// haEntities returns the HA memory reservation of the hosts
+func (c *cluster) haEntities() []map[string]interface{} {
+	result := []map[string]interface{}{}
+	for _, host := range c.hosts {
+		result = append(result, map[string]interface{}{
+			"id":                       host["uuid"],
+			"ha_memory_reserved_bytes": 16 * GIB,
+		})
+	}
+	return result
+}
+
+func (c *cluster) hasEntity(entities []map[string]interface{}, uuid string) bool {
+	for _, ent := range entities {
+		if ent["uuid"] == uuid {
+			return true
+		}
+	}
+	return false
+}
+
+// statsResponse returns the samples of the requested metrics, a saw tooth
+// over the requested time range
+func (c *cluster) statsResponse(query map[string][]string) map[string]interface{} {
+	start, _ := strconv.ParseInt(first(query["start_time_in_usecs"]), 10, 64)
+	end, _ := strconv.ParseInt(first(query["end_time_in_usecs"]), 10, 64)
+	interval, _ := strconv.ParseInt(first(query["interval_in_secs"]), 10, 64)
+	if interval <= 0 {
+		interval = 30
+	}
+	samples := 1
+	if end > start {
+		samples = int((end-start)/(interval*1000000)) + 1
+	}
+
+	responses := []map[string]interface{}{}
+	for _, metric := range strings.Split(first(query["metrics"]), ",") {
+		if len(metric) == 0 {
+			continue
+		}
+		values := make([]int64, samples)
+		for i := range values {
+			values[i] = int64(100000 + (i%10)*10000)
+		}
+		responses = append(responses, map[string]interface{}{
+			"successful":          true,
+			"metric":              metric,
+			"start_time_in_usecs": start,
+			"interval_in_secs":    interval,
+			"values":              values,
+		})
+	}
+	return map[string]interface{}{"stats_specific_responses": responses}
+}
@@ -0,0 +1,237 @@
+// Package fakeprism simulates the Prism Element v1 and v2 REST APIs used by
+// the collectors, for integration tests and demos without a cluster.
+package fakeprism
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const API_PREFIX = "/PrismGateway/services/rest/"
+
+// Config sets the size of the simulated cluster and the faults injected
+type Config struct {
+	Hosts             int
+	VMs               int // guest VMs, one controller VM per host is added
+	NICsPerEntity     int // NICs of every host and VM
+	VDisksPerVM       int
+	StorageContainers int
+	Snapshots         int
+	// Latency is added to every response
+	Latency time.Duration
+	// ErrorRate is the fraction of requests answered with a server error
+	ErrorRate float64
+	// FailPaths lists API paths, e.g. "v2.0/snapshots", always answered with
+	// a server error. A path fails all paths below it.
+	FailPaths []string
+	Seed      int64
+}
+
+// DefaultConfig returns a small three node cluster
+func DefaultConfig() Config {
+	return Config{
+		Hosts:             3,
+		VMs:               20,
+		NICsPerEntity:     1,
+		VDisksPerVM:       2,
+		StorageContainers: 2,
+		Snapshots:         5,
+		Seed:              1,
+	}
+}
+
+// Server is the fake Prism, an http.Handler
+type Server struct {
+	conf     Config
+	cluster  *cluster
+	requests atomic.Uint64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New - create the fake Prism of the given config
+func New(conf Config) *Server {
+	return &Server{
+		conf:    conf,
+		cluster: newCluster(conf),
+		rand:    rand.New(rand.NewSource(conf.Seed)),
+	}
+}
+
+// Requests returns the number of requests served
+func (s *Server) Requests() uint64 {
+	return s.requests.Load()
+}
+
+// ClusterUUID returns the uuid of the simulated cluster
+func (s *Server) ClusterUUID() string {
+	return s.cluster.uuid
+}
+
+// ServeHTTP - Implement http.Handler interface
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+
+	if s.conf.Latency > 0 {
+		select {
+		case <-time.After(s.conf.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if _, _, ok := r.BasicAuth(); !ok {
+		http.Error(w, `{"message": "authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, API_PREFIX) {
+		http.NotFound(w, r)
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, API_PREFIX), "/")
+
+	if s.fail(path) {
+		http.Error(w, `{"message": "injected error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	body, ok := s.route(path, r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// fail returns true if an error is injected for the path
+func (s *Server) fail(path string) bool {
+	for _, failPath := range s.conf.FailPaths {
+		failPath = strings.Trim(failPath, "/")
+		if path == failPath || strings.HasPrefix(path, failPath+"/") {
+			return true
+		}
+	}
+	if s.conf.ErrorRate <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rand.Float64() < s.conf.ErrorRate
+}
+
+// route returns the response of an API path, e.g. "v2.0/hosts"
+func (s *Server) route(path string, r *http.Request) (interface{}, bool) {
+	c := s.cluster
+	parts := strings.Split(path, "/")
+	version, parts := parts[0], parts[1:]
+	query := r.URL.Query()
+
+	switch version {
+	case "v1":
+		switch {
+		case len(parts) == 1 && parts[0] == "vms":
+			return pageV1(c.vmsV1, query), true
+		case len(parts) == 3 && parts[0] == "vms" && parts[2] == "virtual_nics":
+			return nonNil(c.vmNics[parts[1]]), c.hasEntity(c.vmsV1, parts[1])
+		case len(parts) == 2 && parts[0] == "utils" && parts[1] == "entities":
+			return pageV1(c.haEntities(), query), true
+		}
+	case "v2.0":
+		switch {
+		case len(parts) == 1 && parts[0] == "cluster":
+			return c.entity, true
+		case len(parts) == 2 && parts[0] == "cluster" && parts[1] == "stats":
+			return c.statsResponse(query), true
+		case len(parts) == 1 && parts[0] == "hosts":
+			return pageV2(c.hosts, query), true
+		case len(parts) == 3 && parts[0] == "hosts" && parts[2] == "host_nics":
+			return nonNil(c.hostNics[parts[1]]), c.hasEntity(c.hosts, parts[1])
+		case len(parts) == 3 && parts[0] == "hosts" && parts[2] == "stats":
+			return c.statsResponse(query), c.hasEntity(c.hosts, parts[1])
+		case len(parts) == 1 && parts[0] == "vms":
+			return pageV2(c.vmsV2, query), true
+		case len(parts) == 3 && parts[0] == "vms" && parts[2] == "stats":
+			return c.statsResponse(query), c.hasEntity(c.vmsV2, parts[1])
+		case len(parts) == 1 && parts[0] == "virtual_disks":
+			return pageV2(c.vdisks, query), true
+		case len(parts) == 1 && parts[0] == "storage_containers":
+			return pageV2(c.containers, query), true
+		case len(parts) == 1 && parts[0] == "snapshots":
+			return pageV2(c.snapshots, query), true
+		}
+	}
+	return nil, false
+}
+
+// page returns the entities of the page given by the count and page params
+func page(entities []map[string]interface{}, query map[string][]string) ([]map[string]interface{}, int, int) {
+	count, err := strconv.Atoi(first(query["count"]))
+	if err != nil || count <= 0 {
+		count = len(entities)
+	}
+	p, err := strconv.Atoi(first(query["page"]))
+	if err != nil || p <= 0 {
+		p = 1
+	}
+	start := (p - 1) * count
+	if start > len(entities) {
+		start = len(entities)
+	}
+	end := start + count
+	if end > len(entities) {
+		end = len(entities)
+	}
+	return entities[start:end], start, end
+}
+
+// nonNil returns an empty list instead of nil, encoded as [] like Prism does
+func nonNil(entities []map[string]interface{}) []map[string]interface{} {
+	if entities == nil {
+		return []map[string]interface{}{}
+	}
+	return entities
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func pageV1(entities []map[string]interface{}, query map[string][]string) map[string]interface{} {
+	result, start, end := page(entities, query)
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"grandTotalEntities": len(entities),
+			"totalEntities":      len(entities),
+			"count":              len(result),
+			"startIndex":         start + 1,
+			"endIndex":           end,
+		},
+		"entities": result,
+	}
+}
+
+func pageV2(entities []map[string]interface{}, query map[string][]string) map[string]interface{} {
+	result, start, end := page(entities, query)
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"grand_total_entities": len(entities),
+			"total_entities":       len(entities),
+			"count":                len(result),
+			"start_index":          start + 1,
+			"end_index":            end,
+		},
+		"entities": result,
+	}
+}
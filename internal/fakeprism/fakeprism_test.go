@@ -0,0 +1,102 @@
+package fakeprism
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, server *httptest.Server, path string) (int, map[string]interface{}) {
+	req, err := http.NewRequest("GET", server.URL+API_PREFIX+path, nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("admin", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	}
+	return resp.StatusCode, body
+}
+
+func TestPaging(t *testing.T) {
+	conf := DefaultConfig()
+	conf.VMs = 250
+	server := httptest.NewServer(New(conf))
+	defer server.Close()
+
+	status, body := get(t, server, "v2.0/vms/?count=100&page=3")
+	require.Equal(t, http.StatusOK, status)
+	meta := body["metadata"].(map[string]interface{})
+	assert.Equal(t, 50, len(body["entities"].([]interface{})))
+	assert.Equal(t, 250.0, meta["grand_total_entities"])
+	assert.Equal(t, 250.0, meta["end_index"])
+
+	// v1 lists the controller VMs too
+	status, body = get(t, server, "v1/vms/?count=100&page=1")
+	require.Equal(t, http.StatusOK, status)
+	meta = body["metadata"].(map[string]interface{})
+	assert.Equal(t, 100, len(body["entities"].([]interface{})))
+	assert.Equal(t, 253.0, meta["grandTotalEntities"])
+}
+
+func TestFailPaths(t *testing.T) {
+	conf := DefaultConfig()
+	conf.FailPaths = []string{"v2.0/snapshots", "v2.0/hosts"}
+	server := httptest.NewServer(New(conf))
+	defer server.Close()
+
+	status, _ := get(t, server, "v2.0/snapshots/")
+	assert.Equal(t, http.StatusInternalServerError, status)
+	status, _ = get(t, server, "v2.0/hosts/"+entityUUID(KIND_HOST, 0)+"/host_nics/")
+	assert.Equal(t, http.StatusInternalServerError, status)
+	status, _ = get(t, server, "v2.0/cluster/")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = get(t, server, "v2.0/unknown/")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestErrorRate(t *testing.T) {
+	conf := DefaultConfig()
+	conf.ErrorRate = 1
+	server := httptest.NewServer(New(conf))
+	defer server.Close()
+
+	status, _ := get(t, server, "v2.0/cluster/")
+	assert.Equal(t, http.StatusInternalServerError, status)
+}
+
+func TestAuthRequired(t *testing.T) {
+	s := New(DefaultConfig())
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + API_PREFIX + "v2.0/cluster/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, uint64(1), s.Requests())
+}
+
+func TestStatsResponse(t *testing.T) {
+	server := httptest.NewServer(New(DefaultConfig()))
+	defer server.Close()
+
+	status, body := get(t, server, "v2.0/hosts/"+entityUUID(KIND_HOST, 1)+
+		"/stats/?metrics=hypervisor_cpu_usage_ppm&start_time_in_usecs=0&end_time_in_usecs=60000000&interval_in_secs=30")
+	require.Equal(t, http.StatusOK, status)
+	responses := body["stats_specific_responses"].([]interface{})
+	require.Equal(t, 1, len(responses))
+	stat := responses[0].(map[string]interface{})
+	assert.Equal(t, "hypervisor_cpu_usage_ppm", stat["metric"])
+	assert.Equal(t, 3, len(stat["values"].([]interface{})))
+
+	status, _ = get(t, server, "v2.0/hosts/unknown/stats/")
+	assert.Equal(t, http.StatusNotFound, status)
+}
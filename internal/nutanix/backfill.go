@@ -0,0 +1,290 @@
+package nutanix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const BACKFILL_DEFAULT_INTERVAL = 5 * time.Minute
+
+// calculatedStats are computed by the collectors and have no history in Prism
+var calculatedStats = map[string]bool{
+	METRIC_TOTAL_WRITE_IO_SIZE:  true,
+	METRIC_MEM_USAGE_BYTES:      true,
+	METRIC_MEM_FREE_BYTES:       true,
+	METRIC_MEM_SWAPPED_IN_RATE:  true,
+	METRIC_MEM_SWAPPED_OUT_RATE: true,
+	METRIC_HA_RESERVED:          true,
+	"controllerVm":              true,
+	METRIC_VM_HA_PRIORITY:       true,
+	METRIC_VM_AGENT:             true,
+	METRIC_VM_AFFINITY:          true,
+}
+
+// Backfill exports the historical stats of the cluster, hosts and VMs of a
+// section in the OpenMetrics format read by
+// `promtool tsdb create-blocks-from openmetrics`. The metric names and labels
+// are the ones of the collectors.
+type Backfill struct {
+	api      *Nutanix
+	interval time.Duration
+	sources  []backfillSource
+}
+
+// backfillSource is a collector whose stats are backfilled
+type backfillSource struct {
+	exporter *nutanixExporter
+	labels   []string
+	entities func() ([]backfillEntity, error)
+}
+
+// backfillEntity is an entity with its stats endpoint
+type backfillEntity struct {
+	action      string
+	labelValues []string
+}
+
+// backfillSeries is the history of a stat of an entity
+type backfillSeries struct {
+	labels string
+	stat   statSeries
+}
+
+// backfillFamily is a metric family of the OpenMetrics output
+type backfillFamily struct {
+	help     string
+	typ      string
+	sample   string // sample name, differs from the family name for counters
+	key      string
+	exporter *nutanixExporter
+	entries  []backfillSeries
+}
+
+// NewBackfill - create the backfill of a section, interval is the sampling
+// interval requested from Prism
+func NewBackfill(_api *Nutanix, interval time.Duration) *Backfill {
+	if interval <= 0 {
+		interval = BACKFILL_DEFAULT_INTERVAL
+	}
+	return &Backfill{api: _api, interval: interval}
+}
+
+// WithCluster adds the stats of the cluster collector
+func (b *Backfill) WithCluster(c *ClusterExporter) *Backfill {
+	b.sources = append(b.sources, backfillSource{
+		exporter: c.nutanixExporter,
+		labels:   []string{"uuid"},
+		entities: func() ([]backfillEntity, error) {
+			uuid, err := b.api.GetClusterUUID()
+			if err != nil {
+				return nil, err
+			}
+			return []backfillEntity{{action: "/cluster/stats", labelValues: []string{uuid}}}, nil
+		},
+	})
+	return b
+}
+
+// WithHosts adds the stats of the hosts collector
+func (b *Backfill) WithHosts(h *HostsExporter) *Backfill {
+	b.sources = append(b.sources, backfillSource{
+		exporter: h.nutanixExporter,
+		labels:   []string{"uuid", "cluster_uuid"},
+		entities: func() ([]backfillEntity, error) {
+			entities, err := b.api.fetchAllPages("/hosts", nil)
+			if err != nil {
+				return nil, err
+			}
+			result := []backfillEntity{}
+			for _, entRaw := range h.filter.filterEntities(entities, hostFilterKeys) {
+				ent := entRaw.(map[string]interface{})
+				uuid, _ := ent["uuid"].(string)
+				clusterUUID, _ := ent["cluster_uuid"].(string)
+				result = append(result, backfillEntity{action: "/hosts/" + uuid + "/stats", labelValues: []string{uuid, clusterUUID}})
+			}
+			return result, nil
+		},
+	})
+	return b
+}
+
+// WithVms adds the stats of the VMs collector, with the labels of the live
+// series. Must be called after the naming and category labels of the
+// collector are set. The host_uuid label is the current host of the VM,
+// migrations within the time range are not known.
+func (b *Backfill) WithVms(v *VmsExporter) *Backfill {
+	b.sources = append(b.sources, backfillSource{
+		exporter: v.nutanixExporter,
+		labels:   v.labelNames(),
+		entities: func() ([]backfillEntity, error) {
+			entities, err := b.api.fetchAllPagesV1("/vms", nil)
+			if err != nil {
+				return nil, err
+			}
+			v.fetchCategories()
+			result := []backfillEntity{}
+			for _, entRaw := range v.filter.filterEntities(entities, vmFilterKeys) {
+				ent := entRaw.(map[string]interface{})
+				uuid, _ := ent["uuid"].(string)
+				hostUUID, _ := ent["hostUuid"].(string)
+				result = append(result, backfillEntity{action: "/vms/" + uuid + "/stats", labelValues: v.labelValues(uuid, hostUUID)})
+			}
+			return result, nil
+		},
+	})
+	return b
+}
+
+// backfillStats returns the stats of the collector with a history in Prism.
+// Stat patterns can not be resolved without a snapshot and are skipped.
+func (e *nutanixExporter) backfillStats() []string {
+	if e.all_stats || len(e.stat_patterns) > 0 {
+		e.api.Logger().Warnf("Stat patterns of %s are not backfilled", e.namespace)
+	}
+	stats := []string{}
+	for stat, enabled := range e.filter_stats {
+		if enabled && !calculatedStats[stat] {
+			stats = append(stats, stat)
+		}
+	}
+	sort.Strings(stats)
+	return stats
+}
+
+// Write queries the history of the stats between start and end and writes
+// it to w. Entities whose stats can not be queried are logged and counted,
+// the history of the other entities is written anyway.
+func (b *Backfill) Write(w io.Writer, start, end time.Time) error {
+	families := make(map[string]*backfillFamily)
+	var mu sync.Mutex
+	failed := 0
+
+	params := url.Values{}
+	params.Set("start_time_in_usecs", strconv.FormatInt(start.UnixMicro(), 10))
+	params.Set("end_time_in_usecs", strconv.FormatInt(end.UnixMicro(), 10))
+	params.Set("interval_in_secs", strconv.Itoa(int(b.interval.Seconds())))
+
+	for _, source := range b.sources {
+		e := source.exporter
+		stats := e.backfillStats()
+		if len(stats) == 0 {
+			continue
+		}
+		entities, err := source.entities()
+		if err != nil {
+			return fmt.Errorf("%s discovery failed: %w", e.namespace, err)
+		}
+		sourceParams := url.Values{}
+		for k, v := range params {
+			sourceParams[k] = v
+		}
+		sourceParams.Set("metrics", strings.Join(stats, ","))
+
+		var wg sync.WaitGroup
+		semaphore := make(chan struct{}, b.api.maxParallelRequests)
+		for _, entity := range entities {
+			wg.Add(1)
+			go func(entity backfillEntity) {
+				defer wg.Done()
+				semaphore <- struct{}{}        // Acquire a token
+				defer func() { <-semaphore }() // Release the token
+
+				series, err := b.api.fetchStats(entity.action, sourceParams)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					b.api.Logger().Errorf("Stats history of %s failed: %v", entity.action, err)
+					failed++
+					return
+				}
+				labels := formatLabels(source.labels, entity.labelValues)
+				for key, stat := range series {
+					name := prometheus.BuildFQName(e.namespace, "", e.metricName(key))
+					family, ok := families[name]
+					if !ok {
+						family = newBackfillFamily(e, key, name)
+						families[name] = family
+					}
+					family.entries = append(family.entries, backfillSeries{labels: labels, stat: stat})
+				}
+			}(entity)
+		}
+		wg.Wait()
+	}
+
+	if err := writeOpenMetrics(w, families, start, end); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("stats history of %d entities failed", failed)
+	}
+	return nil
+}
+
+func newBackfillFamily(e *nutanixExporter, key, name string) *backfillFamily {
+	family := &backfillFamily{help: e.metricHelp(key), typ: "gauge", sample: name, key: key, exporter: e}
+	if e.isCounter(key) {
+		family.typ = "counter"
+	}
+	return family
+}
+
+// writeOpenMetrics writes the families sorted by name, every series with
+// increasing timestamps
+func writeOpenMetrics(w io.Writer, families map[string]*backfillFamily, start, end time.Time) error {
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		family := families[name]
+		familyName := name
+		if family.typ == "counter" {
+			familyName = strings.TrimSuffix(name, "_total")
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", familyName, escapeOpenMetrics(family.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", familyName, family.typ)
+
+		sort.Slice(family.entries, func(i, j int) bool { return family.entries[i].labels < family.entries[j].labels })
+		for _, series := range family.entries {
+			for i, v := range series.stat.values {
+				ts := series.stat.start.Add(time.Duration(i) * series.stat.interval)
+				// ignore samples which are not available
+				if v < 0 || ts.Before(start) || ts.After(end) {
+					continue
+				}
+				value := family.exporter.metricValue(family.key, v)
+				fmt.Fprintf(bw, "%s%s %s %s\n", family.sample, series.labels,
+					strconv.FormatFloat(value, 'g', -1, 64),
+					strconv.FormatFloat(float64(ts.UnixMilli())/1000, 'f', -1, 64))
+			}
+		}
+	}
+	fmt.Fprintln(bw, "# EOF")
+	return bw.Flush()
+}
+
+// formatLabels formats the label set of a sample
+func formatLabels(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, escapeOpenMetrics(values[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeOpenMetrics(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
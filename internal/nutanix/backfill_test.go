@@ -0,0 +1,114 @@
+package nutanix
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfillWrite(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	end := start.Add(10 * time.Minute)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PrismGateway/services/rest/v2.0/cluster/":
+			w.Write([]byte(`{"uuid": "c1"}`))
+		case "/PrismGateway/services/rest/v2.0/hosts/":
+			w.Write([]byte(`{"metadata": {"grand_total_entities": 1, "end_index": 1}, "entities": [{"uuid": "h1", "cluster_uuid": "c1", "name": "host01"}]}`))
+		case "/PrismGateway/services/rest/v2.0/cluster/stats/", "/PrismGateway/services/rest/v2.0/hosts/h1/stats/":
+			assert.Equal(t, "300", r.URL.Query().Get("interval_in_secs"))
+			assert.NotContains(t, r.URL.Query().Get("metrics"), METRIC_TOTAL_WRITE_IO_SIZE)
+			w.Write([]byte(`{"stats_specific_responses": [
+				{"successful": true, "metric": "hypervisor_cpu_usage_ppm", "start_time_in_usecs": 1700000000000000, "interval_in_secs": 300, "values": [250000, -1, 500000]},
+				{"successful": true, "metric": "hypervisor_num_received_bytes", "start_time_in_usecs": 1700000000000000, "interval_in_secs": 300, "values": [10, 20]}
+			]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	api := NewNutanix(server.URL, "user", "pass", 2)
+	cluster := NewClusterCollector(api)
+	cluster.SetMetricNaming(METRIC_NAMING_V2)
+	hosts := NewHostsCollector(api, false, nil)
+	hosts.SetMetricNaming(METRIC_NAMING_V2)
+
+	var out bytes.Buffer
+	require.NoError(t, NewBackfill(api, 0).WithCluster(cluster).WithHosts(hosts).Write(&out, start, end))
+	text := out.String()
+
+	assert.Contains(t, text, "# TYPE nutanix_hosts_hypervisor_cpu_usage_ratio gauge\n"+
+		"nutanix_hosts_hypervisor_cpu_usage_ratio{uuid=\"h1\",cluster_uuid=\"c1\"} 0.25 1700000000\n"+
+		"nutanix_hosts_hypervisor_cpu_usage_ratio{uuid=\"h1\",cluster_uuid=\"c1\"} 0.5 1700000600\n")
+	assert.Contains(t, text, "# TYPE nutanix_cluster_hypervisor_received_bytes counter\n"+
+		"nutanix_cluster_hypervisor_received_bytes_total{uuid=\"c1\"} 10 1700000000\n")
+	assert.True(t, strings.HasSuffix(text, "# EOF\n"))
+}
+
+func TestBackfillWriteFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/PrismGateway/services/rest/v2.0/cluster/" {
+			w.Write([]byte(`{"uuid": "c1"}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	api := NewNutanix(server.URL, "user", "pass", 2)
+	var out bytes.Buffer
+	err := NewBackfill(api, time.Minute).WithCluster(NewClusterCollector(api)).Write(&out, time.Now().Add(-time.Hour), time.Now())
+	require.Error(t, err)
+	assert.Equal(t, "# EOF\n", out.String())
+}
+
+func TestBackfillVms(t *testing.T) {
+	vmCategoriesMu.Lock()
+	vmCategoriesCache = make(map[string]*vmCategoriesEntry)
+	vmCategoriesMu.Unlock()
+
+	start := time.Unix(1700000000, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PrismGateway/services/rest/v1/vms/":
+			w.Write([]byte(`{"metadata": {"grandTotalEntities": 1, "endIndex": 1}, "entities": [{"uuid": "vm1", "vmName": "db01", "hostUuid": "h1"}]}`))
+		case "/PrismGateway/services/rest/v2.0/cluster/":
+			w.Write([]byte(`{"uuid": "c1"}`))
+		case "/api/nutanix/v3/vms/list":
+			w.Write([]byte(`{"metadata": {"total_matches": 1}, "entities": [{
+				"status": {"cluster_reference": {"uuid": "c1"}},
+				"metadata": {"uuid": "vm1", "categories": {"AppType": "Oracle"}}}]}`))
+		case "/PrismGateway/services/rest/v2.0/vms/vm1/stats/":
+			w.Write([]byte(`{"stats_specific_responses": [
+				{"successful": true, "metric": "hypervisor_cpu_usage_ppm", "start_time_in_usecs": 1700000000000000, "interval_in_secs": 300, "values": [250000]}
+			]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	api := NewNutanix(server.URL, "user", "pass", 2)
+
+	backfill := func(naming string) string {
+		vms := NewVmsCollector(api, false)
+		vms.SetMetricNaming(naming)
+		vms.WithCategoryLabels(NewVmCategories(api, api, time.Minute), []string{"AppType"})
+		var out bytes.Buffer
+		require.NoError(t, NewBackfill(api, 0).WithVms(vms).Write(&out, start, start.Add(5*time.Minute)))
+		return out.String()
+	}
+
+	// The labels match the ones of the live series
+	assert.Contains(t, backfill(METRIC_NAMING_V1),
+		"nutanix_vms_hypervisor_cpu_usage_ppm{uuid=\"vm1\",host_uuid=\"h1\",category_apptype=\"Oracle\"} 250000 1700000000\n")
+	assert.Contains(t, backfill(METRIC_NAMING_V2),
+		"nutanix_vms_hypervisor_cpu_usage_ratio{uuid=\"vm1\",category_apptype=\"Oracle\"} 0.25 1700000000\n")
+}
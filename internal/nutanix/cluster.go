@@ -14,7 +14,6 @@ import (
 	"fmt"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
 const KEY_CLUSTER_PROPERTIES = "properties"
@@ -31,7 +30,7 @@ func (e *ClusterExporter) Describe(ch chan<- *prometheus.Desc) {
 	resp, err := e.api.makeV2Request("GET", "/cluster/", nil)
 	if err != nil {
 		e.result = nil
-		log.Error("Cluster discovery failed")
+		e.api.Logger().Error("Cluster discovery failed")
 		return
 	}
 	data := json.NewDecoder(resp.Body)
@@ -49,46 +48,28 @@ func (e *ClusterExporter) Describe(ch chan<- *prometheus.Desc) {
 
 	// Publish cluster properties as separate record
 	key := KEY_CLUSTER_PROPERTIES
-	e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: e.namespace,
-		Name:      key, Help: "..."}, e.properties)
-	e.metrics[key].Describe(ch)
+	e.describeStat(ch, key, e.properties)
 
 	if usageStats != nil {
 		for key := range usageStats {
-			if _, ok := e.filter_stats[key]; !ok {
+			if !e.statEnabled(key) {
 				continue
 			}
-			key = e.normalizeKey(key)
-
-			e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-				Namespace: e.namespace,
-				Name:      key, Help: "..."}, []string{"uuid"})
-
-			e.metrics[key].Describe(ch)
+			e.describeStat(ch, key, []string{"uuid"})
 		}
 	}
 	if stats != nil {
 		e.addCalculatedStats(stats)
 		for key := range stats {
-			if _, ok := e.filter_stats[key]; !ok {
+			if !e.statEnabled(key) {
 				continue
 			}
 
-			key = e.normalizeKey(key)
-
-			e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-				Namespace: e.namespace,
-				Name:      key, Help: "..."}, []string{"uuid"})
-
-			e.metrics[key].Describe(ch)
+			e.describeStat(ch, key, []string{"uuid"})
 		}
 	}
 	for _, key := range e.fields {
-		e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: e.namespace,
-			Name:      key, Help: "..."}, []string{"uuid"})
-		e.metrics[key].Describe(ch)
+		e.describeStat(ch, key, []string{"uuid"})
 	}
 }
 
@@ -146,7 +127,7 @@ func (e *ClusterExporter) Collect(ch chan<- prometheus.Metric) {
 
 	if usageStats != nil {
 		for key, value := range usageStats {
-			if _, ok := e.filter_stats[key]; !ok {
+			if !e.statEnabled(key) {
 				continue
 			}
 
@@ -155,15 +136,12 @@ func (e *ClusterExporter) Collect(ch chan<- prometheus.Metric) {
 			if v == -1 {
 				continue
 			}
-			key = e.normalizeKey(key)
-			g := e.metrics[key].WithLabelValues(ent["uuid"].(string))
-			g.Set(v)
-			g.Collect(ch)
+			e.collectStat(ch, key, v, ent["uuid"].(string))
 		}
 	}
 	if stats != nil {
 		for key, value := range stats {
-			if _, ok := e.filter_stats[key]; !ok {
+			if !e.statEnabled(key) {
 				continue
 			}
 
@@ -172,18 +150,13 @@ func (e *ClusterExporter) Collect(ch chan<- prometheus.Metric) {
 			if v == -1 {
 				continue
 			}
-			key = e.normalizeKey(key)
-			g := e.metrics[key].WithLabelValues(ent["uuid"].(string))
-			g.Set(v)
-			g.Collect(ch)
+			e.collectStat(ch, key, v, ent["uuid"].(string))
 		}
 	}
 	for _, key := range e.fields {
-		g := e.metrics[key].WithLabelValues(ent["uuid"].(string))
-		g.Set(e.valueToFloat64(ent[key]))
-		g.Collect(ch)
+		e.collectStat(ch, key, e.valueToFloat64(ent[key]), ent["uuid"].(string))
 	}
-	log.Debug("Cluster data collected for UUID : ", ent["uuid"].(string))
+	e.api.Logger().Debug("Cluster data collected for UUID : ", ent["uuid"].(string))
 }
 
 // NewClusterCollector
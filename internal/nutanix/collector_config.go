@@ -0,0 +1,218 @@
+package nutanix
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const STATS_ALL = "all"
+
+// knownProperties lists the entity attributes a collector can publish as
+// property label or field. Derived attributes (e.g. *_in_mb) are formatted by
+// the collectors.
+var knownProperties = map[string][]string{
+	"cluster": {
+		"uuid", "id", "name", "cluster_external_ipaddress", "cluster_external_data_services_ipaddress",
+		"timezone", "version", "full_version", "target_version", "ncc_version", "num_nodes", "is_lts",
+		"storage_type", "operation_mode", "encrypted", "enable_shadow_clones", "has_self_encrypting_drive",
+	},
+	"hosts": {
+		"uuid", "cluster_uuid", "name", "host_type", "hypervisor_address", "hypervisor_key",
+		"hypervisor_full_name", "hypervisor_type", "hypervisor_state", "serial", "block_serial",
+		"block_model", "block_model_name", "block_location", "position", "service_vmid",
+		"service_vmexternal_ip", "controller_vm_backplane_ip", "ipmi_address", "management_server_name",
+		"cpu_model", "bios_version", "bmc_version", "state", "metadata_store_status",
+		"host_in_maintenance_mode", "is_degraded", "reboot_pending", "num_vms", "num_cpu_cores",
+		"num_cpu_sockets", "num_cpu_threads", "cpu_frequency_in_hz", "cpu_frequency_in_mhz",
+		"cpu_capacity_in_hz", "cpu_capacity_in_mhz", "memory_capacity_in_bytes", "memory_capacity_in_mb",
+		"oplog_disk_size", "boot_time_in_usecs",
+	},
+	"vms": {
+		"uuid", "vmId", "vmName", "hostUuid", "hostName", "clusterUuid", "powerState", "controllerVm",
+		"hypervisorType", "description", "protectionType", "consistencyGroupName", "ipAddresses",
+		"numVCpus", "numNetworkAdapters", "memoryCapacityInBytes", "memoryCapacityInMB",
+		"memoryReservedCapacityInBytes", "memoryReservedCapacityInMB", "cpuReservedInHz",
+		"cpuReservedInMHz", "diskCapacityInBytes", "diskCapacityInMB",
+	},
+	"storage_containers": {
+		"storage_container_uuid", "id", "cluster_uuid", "name", "replication_factor",
+		"compression_enabled", "compression_delay_in_secs", "on_disk_dedup", "finger_print_on_write",
+		"erasure_code", "max_capacity", "max_capacity_mb", "advertised_capacity",
+		"total_explicit_reserved_capacity", "total_implicit_reserved_capacity", "is_nutanix_managed",
+		"enable_software_encryption", "encrypted",
+	},
+	"virtual_disks": {
+		"uuid", "cluster_uuid", "storage_container_uuid", "attached_vm_uuid", "attached_vmname",
+		"attached_volume_group_id", "disk_address", "device_uuid", "nutanix_nfsfile_path",
+		"flash_mode_enabled", "disk_capacity_in_bytes", "disk_capacity_in_mb",
+	},
+	"hostnics": {
+		"node_uuid", "uuid", "hostname", "name", "mac_address", "ipv4_addresses", "mtu_in_bytes",
+		"link_speed_in_kbps",
+	},
+	"vmnics": {
+		"vmUuid", "uuid", "vmName", "name", "macAddress", "ipv4Addresses", "mtuInBytes", "networkUuid",
+		"adapterType",
+	},
+	"volume_groups": {
+		"uuid", "name", "description", "iscsi_target", "iscsi_initiator_names", "flash_mode_enabled",
+		"is_shared", "logical_timestamp", METRIC_VG_NUM_DISKS, METRIC_VG_CAPACITY_BYTES,
+	},
+	"images": {
+		"uuid", "name", "annotation", "image_type", "image_state", "storage_container_uuid", "vm_disk_id",
+		"vm_disk_size", "created_time_in_usecs", "updated_time_in_usecs", METRIC_IMAGE_SIZE_BYTES,
+	},
+	"networks": {
+		"uuid", "name", "vlan_id", "vswitch_name", "network_type", NETWORK_MANAGED_PROPERTY,
+		METRIC_NET_POOL_SIZE, METRIC_NET_ASSIGNED_ADDRS, METRIC_NET_FREE_ADDRS, METRIC_NET_VM_NICS,
+	},
+	"snapshots": {
+		"created_time", "logical_timestamp",
+	},
+}
+
+// statelessCollectors publish no Prism stats, only fields and properties
+var statelessCollectors = map[string]bool{"images": true, "networks": true, "snapshots": true}
+
+// fieldsOnlyCollectors publish no properties
+var fieldsOnlyCollectors = map[string]bool{"snapshots": true}
+
+// StatsList is a list of stat names or glob patterns, or "all" to publish
+// every stat reported by Prism
+type StatsList struct {
+	All   bool
+	Names []string
+}
+
+// UnmarshalYAML accepts either "all" or a list of stats
+func (l *StatsList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var all string
+	if err := unmarshal(&all); err == nil {
+		if all != STATS_ALL {
+			return fmt.Errorf("stats must be a list or %q, got %q", STATS_ALL, all)
+		}
+		l.All = true
+		return nil
+	}
+	return unmarshal(&l.Names)
+}
+
+// CollectorConfig overrides or extends the stats, fields and properties
+// published by a collector. Unset lists keep the collector defaults.
+type CollectorConfig struct {
+	Stats           *StatsList `yaml:"stats"`
+	ExtraStats      []string   `yaml:"extra_stats"`
+	Fields          []string   `yaml:"fields"`
+	ExtraFields     []string   `yaml:"extra_fields"`
+	Properties      []string   `yaml:"properties"`
+	ExtraProperties []string   `yaml:"extra_properties"`
+	// Stats queried from the per entity stats endpoints
+	TimeSeries *TimeSeriesConfig `yaml:"time_series"`
+}
+
+// Validate checks the config against the attributes known for the collector
+func (c *CollectorConfig) Validate(collector string) error {
+	known, ok := knownProperties[collector]
+	if !ok {
+		return fmt.Errorf("collector %s is not configurable", collector)
+	}
+	if c == nil {
+		return nil
+	}
+
+	if statelessCollectors[collector] && (c.Stats != nil || len(c.ExtraStats) > 0) {
+		return fmt.Errorf("stats are not supported for collector %s", collector)
+	}
+	if fieldsOnlyCollectors[collector] && (c.Properties != nil || len(c.ExtraProperties) > 0) {
+		return fmt.Errorf("properties are not supported for collector %s", collector)
+	}
+
+	stats := c.ExtraStats
+	if c.Stats != nil {
+		stats = append(append([]string{}, c.Stats.Names...), stats...)
+	}
+	for _, stat := range stats {
+		if _, err := path.Match(stat, ""); err != nil {
+			return fmt.Errorf("invalid stat pattern %q: %w", stat, err)
+		}
+	}
+
+	isKnown := make(map[string]bool, len(known))
+	for _, property := range known {
+		isKnown[property] = true
+	}
+	for _, list := range [][]string{c.Fields, c.ExtraFields, c.Properties, c.ExtraProperties} {
+		for _, property := range list {
+			if !isKnown[property] {
+				return fmt.Errorf("unknown property %q for collector %s", property, collector)
+			}
+		}
+	}
+	if c.TimeSeries != nil {
+		return c.TimeSeries.Validate(collector)
+	}
+	return nil
+}
+
+// isStatPattern returns true if the stat name contains glob characters
+func isStatPattern(stat string) bool {
+	return strings.ContainsAny(stat, "*?[")
+}
+
+// appendUnique appends the values not yet contained in list
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, v := range list {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// Configure applies the collector config on top of the defaults of the
+// collector. Must be called before the collector is registered.
+func (e *nutanixExporter) Configure(conf *CollectorConfig) {
+	if conf == nil {
+		return
+	}
+
+	addStat := func(stat string) {
+		if isStatPattern(stat) {
+			e.stat_patterns = appendUnique(e.stat_patterns, stat)
+		} else {
+			e.filter_stats[stat] = true
+		}
+	}
+	if conf.Stats != nil {
+		e.filter_stats = make(map[string]bool)
+		e.stat_patterns = nil
+		e.all_stats = conf.Stats.All
+		for _, stat := range conf.Stats.Names {
+			addStat(stat)
+		}
+	}
+	if e.filter_stats == nil {
+		e.filter_stats = make(map[string]bool)
+	}
+	for _, stat := range conf.ExtraStats {
+		addStat(stat)
+	}
+
+	if conf.Fields != nil {
+		e.fields = appendUnique(nil, conf.Fields...)
+	}
+	e.fields = appendUnique(e.fields, conf.ExtraFields...)
+	if conf.Properties != nil {
+		e.properties = appendUnique(nil, conf.Properties...)
+	}
+	e.properties = appendUnique(e.properties, conf.ExtraProperties...)
+	e.time_series = conf.TimeSeries
+}
@@ -0,0 +1,73 @@
+package nutanix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestCollectorConfigUnmarshal(t *testing.T) {
+	var conf map[string]*CollectorConfig
+	err := yaml.Unmarshal([]byte(`
+vms:
+  stats: all
+hosts:
+  stats: [hypervisor_cpu_usage_ppm, "controller_*"]
+`), &conf)
+	require.NoError(t, err)
+	assert.True(t, conf["vms"].Stats.All)
+	assert.False(t, conf["hosts"].Stats.All)
+	assert.Equal(t, []string{"hypervisor_cpu_usage_ppm", "controller_*"}, conf["hosts"].Stats.Names)
+
+	err = yaml.Unmarshal([]byte("vms:\n  stats: some\n"), &conf)
+	assert.Error(t, err)
+}
+
+func TestCollectorConfigValidate(t *testing.T) {
+	assert.NoError(t, (&CollectorConfig{ExtraProperties: []string{"hypervisorType"}}).Validate("vms"))
+	assert.Error(t, (&CollectorConfig{ExtraProperties: []string{"unknown"}}).Validate("vms"))
+	assert.Error(t, (&CollectorConfig{Fields: []string{"vmName"}}).Validate("hosts"))
+	assert.Error(t, (&CollectorConfig{ExtraStats: []string{"["}}).Validate("vms"))
+	assert.Error(t, (&CollectorConfig{}).Validate("tasks"))
+
+	assert.NoError(t, (&CollectorConfig{ExtraProperties: []string{"networkUuid"}}).Validate("vmnics"))
+	assert.NoError(t, (&CollectorConfig{Stats: &StatsList{All: true}}).Validate("volume_groups"))
+	assert.Error(t, (&CollectorConfig{ExtraStats: []string{"controller_num_iops"}}).Validate("images"))
+	assert.Error(t, (&CollectorConfig{ExtraProperties: []string{"created_time"}}).Validate("snapshots"))
+}
+
+func TestConfigure(t *testing.T) {
+	e := NewVmsCollector(&Nutanix{}, false)
+	e.Configure(&CollectorConfig{
+		ExtraStats:      []string{"controller_io_bandwidth_kBps", "controller_avg_*"},
+		ExtraProperties: []string{"vmName", "hypervisorType"},
+	})
+	assert.True(t, e.statEnabled("hypervisor_cpu_usage_ppm"))
+	assert.True(t, e.statEnabled("controller_io_bandwidth_kBps"))
+	assert.True(t, e.statEnabled("controller_avg_read_io_latency_usecs"))
+	assert.False(t, e.statEnabled("controller_num_iops"))
+	assert.Equal(t, "hypervisorType", e.properties[len(e.properties)-1])
+	assert.Equal(t, 1, countOf(e.properties, "vmName"))
+
+	// Replace the defaults
+	e = NewVmsCollector(&Nutanix{}, false)
+	e.Configure(&CollectorConfig{Stats: &StatsList{Names: []string{"controller_num_iops"}}, Fields: []string{"numVCpus"}})
+	assert.False(t, e.statEnabled("hypervisor_cpu_usage_ppm"))
+	assert.True(t, e.statEnabled("controller_num_iops"))
+	assert.Equal(t, []string{"numVCpus"}, e.fields)
+
+	e.Configure(&CollectorConfig{Stats: &StatsList{All: true}})
+	assert.True(t, e.statEnabled("anything"))
+}
+
+func countOf(list []string, value string) int {
+	n := 0
+	for _, v := range list {
+		if v == value {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,116 @@
+package nutanix
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// COUNTER_SERIES_TTL is the time after which the last value of a counter
+// series no longer seen is forgotten
+const COUNTER_SERIES_TTL = time.Hour
+
+// counterState keeps the last value of every counter series of a section to
+// detect counter resets reported by Prism (e.g. after a VM power cycle or
+// migration). Collectors are created per scrape, so the state has to outlive them.
+type counterState struct {
+	mu     sync.Mutex
+	last   map[string]float64   // series -> last value
+	seen   map[string]time.Time // series -> last scrape the series was seen
+	resets map[string]uint64    // metric -> resets since exporter start
+	pruned time.Time
+}
+
+var (
+	counterStatesMu        sync.Mutex
+	counterStatesBySection = map[string]*counterState{}
+)
+
+func getCounterState(section string) *counterState {
+	counterStatesMu.Lock()
+	defer counterStatesMu.Unlock()
+	s, ok := counterStatesBySection[section]
+	if !ok {
+		s = &counterState{
+			last:   make(map[string]float64),
+			seen:   make(map[string]time.Time),
+			resets: make(map[string]uint64),
+		}
+		counterStatesBySection[section] = s
+	}
+	return s
+}
+
+// record stores the value of a series and returns true if the value went
+// backwards since the last scrape
+func (s *counterState) record(metric string, labelValues []string, value float64, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.pruned) > COUNTER_SERIES_TTL {
+		for series, seen := range s.seen {
+			if now.Sub(seen) > COUNTER_SERIES_TTL {
+				delete(s.seen, series)
+				delete(s.last, series)
+			}
+		}
+		s.pruned = now
+	}
+
+	series := metric + "\xff" + strings.Join(labelValues, "\xff")
+	last, ok := s.last[series]
+	s.last[series] = value
+	s.seen[series] = now
+	if ok && value < last {
+		s.resets[metric]++
+		return true
+	}
+	return false
+}
+
+// resetCounts returns a copy of the resets per metric
+func (s *counterState) resetCounts() map[string]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(map[string]uint64, len(s.resets))
+	for metric, count := range s.resets {
+		result[metric] = count
+	}
+	return result
+}
+
+// checkCounterReset records the value of a counter series and logs resets
+func (e *nutanixExporter) checkCounterReset(metric string, labelValues []string, value float64) {
+	if getCounterState(e.api.url).record(metric, labelValues, value, time.Now()) {
+		e.api.Logger().Debugf("Counter reset detected for %s%v", metric, labelValues)
+	}
+}
+
+var descCounterResets = prometheus.NewDesc("nutanix_exporter_counter_resets_total", "Counter resets reported by Prism since exporter start", []string{"metric"}, nil)
+
+// ExporterStatsCollector exposes statistics of the exporter about the
+// metrics of a section
+type ExporterStatsCollector struct {
+	section string
+}
+
+// Describe - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (c *ExporterStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descCounterResets
+}
+
+// Collect - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (c *ExporterStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	for metric, count := range getCounterState(c.section).resetCounts() {
+		ch <- prometheus.MustNewConstMetric(descCounterResets, prometheus.CounterValue, float64(count), metric)
+	}
+}
+
+// NewExporterStatsCollector
+func NewExporterStatsCollector(_api *Nutanix) *ExporterStatsCollector {
+	return &ExporterStatsCollector{section: _api.url}
+}
@@ -0,0 +1,86 @@
+package nutanix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterStateRecord(t *testing.T) {
+	// Reset global state
+	counterStatesBySection = map[string]*counterState{}
+
+	s := getCounterState("test")
+	now := time.Now()
+	assert.False(t, s.record("m", []string{"a"}, 100, now))
+	assert.False(t, s.record("m", []string{"a"}, 150, now))
+	assert.False(t, s.record("m", []string{"b"}, 10, now))
+	assert.True(t, s.record("m", []string{"a"}, 20, now))
+	assert.Equal(t, map[string]uint64{"m": 1}, s.resetCounts())
+
+	// Series not seen for a long time are forgotten
+	later := now.Add(2 * COUNTER_SERIES_TTL)
+	assert.False(t, s.record("m", []string{"b"}, 5, later))
+}
+
+func TestCollectCounterStat(t *testing.T) {
+	// Reset global state
+	counterStatesBySection = map[string]*counterState{}
+
+	e := &nutanixExporter{metrics: make(map[string]*prometheus.GaugeVec), namespace: "nutanix_vmnics"}
+	e.SetMetricNaming(METRIC_NAMING_V2)
+
+	descs := make(chan *prometheus.Desc, 10)
+	e.describeStat(descs, "network.received_bytes", []string{"uuid"})
+	e.describeStat(descs, "controller_avg_io_latency_usecs", []string{"uuid"})
+	close(descs)
+	require.Len(t, descs, 2)
+
+	ch := make(chan prometheus.Metric, 10)
+	e.collectStat(ch, "network.received_bytes", 1000, "nic1")
+	e.collectStat(ch, "network.received_bytes", 10, "nic1")
+	e.collectStat(ch, "controller_avg_io_latency_usecs", 1000, "nic1")
+	close(ch)
+
+	var m dto.Metric
+	counter := <-ch
+	assert.Contains(t, counter.Desc().String(), "nutanix_vmnics_network_received_bytes_total")
+	require.NoError(t, counter.Write(&m))
+	require.NotNil(t, m.Counter)
+	assert.Equal(t, float64(1000), m.Counter.GetValue())
+
+	<-ch
+	gauge := <-ch
+	require.NoError(t, gauge.Write(&m))
+	require.NotNil(t, m.Gauge)
+	assert.Equal(t, 0.001, m.Gauge.GetValue())
+
+	assert.Equal(t, map[string]uint64{"nutanix_vmnics_network_received_bytes_total": 1}, getCounterState("").resetCounts())
+}
+
+func TestCounterStatV1(t *testing.T) {
+	// Reset global state
+	counterStatesBySection = map[string]*counterState{}
+
+	// v1 keeps the cumulative stats as gauges
+	e := &nutanixExporter{metrics: make(map[string]*prometheus.GaugeVec), namespace: "nutanix_vmnics"}
+	descs := make(chan *prometheus.Desc, 10)
+	e.describeStat(descs, "network.received_bytes", []string{"uuid"})
+	close(descs)
+	assert.Contains(t, (<-descs).String(), `"nutanix_vmnics_network_received_bytes"`)
+
+	ch := make(chan prometheus.Metric, 10)
+	e.collectStat(ch, "network.received_bytes", 1000, "nic1")
+	e.collectStat(ch, "network.received_bytes", 10, "nic1")
+	close(ch)
+
+	var m dto.Metric
+	require.NoError(t, (<-ch).Write(&m))
+	require.NotNil(t, m.Gauge)
+	// Values going backwards are no counter resets
+	assert.Empty(t, getCounterState("").resetCounts())
+}
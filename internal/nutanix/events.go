@@ -0,0 +1,163 @@
+package nutanix
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// eventKey identifies the labels of an events counter
+type eventKey struct {
+	eventType, severity, entityType string
+}
+
+// eventsState keeps the event cursor and counters per section.
+// Collectors are created per scrape, so the state has to outlive them.
+type eventsState struct {
+	mu sync.Mutex
+	// created time of the newest event counted so far
+	lastSeenUsecs int64
+	// ids of the events counted with the created time lastSeenUsecs
+	lastSeenIDs map[string]bool
+	counts      map[eventKey]uint64
+}
+
+var (
+	eventsMu        sync.Mutex
+	eventsBySection = map[string]*eventsState{}
+)
+
+// getEventsState returns the state of the section; a new state starts counting
+// at the given time, so only events since exporter start are counted
+func getEventsState(section string, start time.Time) *eventsState {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	s, ok := eventsBySection[section]
+	if !ok {
+		s = &eventsState{
+			lastSeenUsecs: start.UnixMicro(),
+			lastSeenIDs:   make(map[string]bool),
+			counts:        make(map[eventKey]uint64),
+		}
+		eventsBySection[section] = s
+	}
+	return s
+}
+
+// cursor returns the created time from which events have to be read. Events
+// created at the time of the newest event counted are read again, to count
+// the ones arriving late with the same time.
+func (s *eventsState) cursor() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSeenUsecs
+}
+
+// record counts events not counted yet and moves the cursor to the newest
+// event. Events created at the cursor are told apart by id. Returns a copy of
+// the counters.
+func (s *eventsState) record(events []interface{}) map[eventKey]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newest, newestIDs := s.lastSeenUsecs, s.lastSeenIDs
+	for _, evRaw := range events {
+		ev, ok := evRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		createdF, _ := ev["created_time_stamp_in_usecs"].(float64)
+		created := int64(createdF)
+		id, _ := ev["id"].(string)
+		// ignore events already counted by an overlapping query, events at
+		// the cursor without id can not be told apart
+		if created < s.lastSeenUsecs || (created == s.lastSeenUsecs && (len(id) == 0 || s.lastSeenIDs[id])) {
+			continue
+		}
+		if created > newest {
+			newest, newestIDs = created, make(map[string]bool)
+		}
+		if created == newest && len(id) > 0 {
+			newestIDs[id] = true
+		}
+		s.counts[newEventKey(ev)]++
+	}
+	s.lastSeenUsecs, s.lastSeenIDs = newest, newestIDs
+
+	counts := make(map[eventKey]uint64, len(s.counts))
+	for key, count := range s.counts {
+		counts[key] = count
+	}
+	return counts
+}
+
+func newEventKey(ev map[string]interface{}) eventKey {
+	key := eventKey{}
+	key.eventType, _ = ev["alert_type_uuid"].(string)
+	if severity, ok := ev["severity"].(string); ok {
+		// Prism reports severities as kInfo, kWarning, kCritical, ...
+		key.severity = strings.ToLower(strings.TrimPrefix(severity, "k"))
+	}
+	if entities, ok := ev["affected_entities"].([]interface{}); ok && len(entities) > 0 {
+		if entity, ok := entities[0].(map[string]interface{}); ok {
+			if entityType, ok := entity["entity_type"].(string); ok {
+				key.entityType = strings.ToLower(entityType)
+			}
+		}
+	}
+	return key
+}
+
+var descEventsTotal = prometheus.NewDesc("nutanix_events_total", "Prism events observed since exporter start", []string{"event_type", "severity", "entity_type"}, nil)
+
+// exporterStart is the time events are counted from
+var exporterStart = time.Now()
+
+// EventsExporter
+type EventsExporter struct {
+	*nutanixExporter
+	counts map[eventKey]uint64
+}
+
+// Describe - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *EventsExporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descEventsTotal
+
+	state := getEventsState(e.api.url, exporterStart)
+
+	params := url.Values{}
+	params.Set("start_time_in_usecs", fmt.Sprintf("%d", state.cursor()))
+	entities, err := e.api.fetchAllPages("/events", params)
+	if err != nil {
+		e.counts = nil
+		e.api.Logger().Error("Event discovery failed")
+		return
+	}
+
+	e.counts = state.record(entities)
+	e.api.Logger().Debugf("Events read: %d", len(entities))
+}
+
+// Collect - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *EventsExporter) Collect(ch chan<- prometheus.Metric) {
+	for key, count := range e.counts {
+		ch <- prometheus.MustNewConstMetric(descEventsTotal, prometheus.CounterValue, float64(count), key.eventType, key.severity, key.entityType)
+	}
+}
+
+// NewEventsCollector
+func NewEventsCollector(_api *Nutanix) *EventsExporter {
+	return &EventsExporter{
+		nutanixExporter: &nutanixExporter{
+			api:       *_api,
+			metrics:   make(map[string]*prometheus.GaugeVec),
+			namespace: "nutanix_events",
+		},
+	}
+}
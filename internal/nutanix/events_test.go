@@ -0,0 +1,64 @@
+package nutanix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventsRecord(t *testing.T) {
+	// Reset global state
+	eventsMu.Lock()
+	eventsBySection = make(map[string]*eventsState)
+	eventsMu.Unlock()
+
+	start := time.UnixMicro(1000)
+	s := getEventsState("test-section", start)
+	assert.Equal(t, int64(1000), s.cursor())
+
+	migrate := map[string]interface{}{
+		"alert_type_uuid":             "VmMigrateAudit",
+		"severity":                    "kInfo",
+		"created_time_stamp_in_usecs": float64(2000),
+		"affected_entities":           []interface{}{map[string]interface{}{"entity_type": "VM"}},
+	}
+	login := map[string]interface{}{
+		"id":                          "e2",
+		"alert_type_uuid":             "LoginInfoAudit",
+		"severity":                    "kAudit",
+		"created_time_stamp_in_usecs": float64(3000),
+	}
+	old := map[string]interface{}{
+		"alert_type_uuid":             "VmMigrateAudit",
+		"severity":                    "kInfo",
+		"created_time_stamp_in_usecs": float64(500),
+	}
+
+	counts := s.record([]interface{}{migrate, login, old})
+	assert.Equal(t, map[eventKey]uint64{
+		{"VmMigrateAudit", "info", "vm"}: 1,
+		{"LoginInfoAudit", "audit", ""}:  1,
+	}, counts)
+	assert.Equal(t, int64(3000), s.cursor())
+
+	// Events returned again are not counted twice
+	counts = s.record([]interface{}{login})
+	assert.Equal(t, uint64(1), counts[eventKey{"LoginInfoAudit", "audit", ""}])
+
+	// Events arriving late with the time of the cursor are counted once
+	late := map[string]interface{}{
+		"id":                          "e3",
+		"alert_type_uuid":             "LoginInfoAudit",
+		"severity":                    "kAudit",
+		"created_time_stamp_in_usecs": float64(3000),
+	}
+	counts = s.record([]interface{}{login, late})
+	assert.Equal(t, uint64(2), counts[eventKey{"LoginInfoAudit", "audit", ""}])
+	counts = s.record([]interface{}{login, late})
+	assert.Equal(t, uint64(2), counts[eventKey{"LoginInfoAudit", "audit", ""}])
+	assert.Equal(t, int64(3000), s.cursor())
+
+	// An existing state keeps its cursor
+	assert.Equal(t, int64(3000), getEventsState("test-section", time.Now()).cursor())
+}
@@ -10,6 +10,7 @@
 package nutanix
 
 import (
+	"path"
 	"strconv"
 	"strings"
 
@@ -24,6 +25,36 @@ type nutanixExporter struct {
 	fields       []string
 	properties   []string
 	filter_stats map[string]bool
+	// stat_patterns and all_stats extend filter_stats, see Configure
+	stat_patterns []string
+	all_stats     bool
+	filter        *EntityFilter
+	naming        string
+	counters      map[string]*prometheus.Desc // counter stats, see describeStat
+	// properties and fields published apart from the info metric, see info.go
+	volatileProperties map[string]bool
+	volatileFields     []string
+	// stats queried from the per entity stats endpoints, see time_series.go
+	time_series *TimeSeriesConfig
+	timeSeries  map[string]map[string]timeSeriesValue // entity uuid -> stat -> value
+}
+
+// SetFilter restricts the entities exported by the collector
+func (e *nutanixExporter) SetFilter(filter *EntityFilter) {
+	e.filter = filter
+}
+
+// statEnabled returns true if the stat is published by the collector
+func (e *nutanixExporter) statEnabled(key string) bool {
+	if e.all_stats || e.filter_stats[key] {
+		return true
+	}
+	for _, pattern := range e.stat_patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
 }
 
 // ValueToFloat64 converts given value to Float64
@@ -36,11 +67,38 @@ func (e *nutanixExporter) valueToFloat64(value interface{}) float64 {
 	case string:
 		v, _ = strconv.ParseFloat(value.(string), 64)
 		break
+	case bool:
+		if value.(bool) {
+			v = 1
+		}
 	}
 
 	return v
 }
 
+// collectStateSet publishes one series per known state of an enumeration,
+// set to 1 for the current state and 0 otherwise. The metric must be registered
+// with the given labels followed by a "state" label.
+func (e *nutanixExporter) collectStateSet(ch chan<- prometheus.Metric, key string, labels []string, states []string, current string) {
+	known := false
+	for _, state := range states {
+		var val float64 = 0
+		if state == current {
+			val = 1
+			known = true
+		}
+		g := e.metrics[key].WithLabelValues(append(labels, state)...)
+		g.Set(val)
+		g.Collect(ch)
+	}
+	// publish states not known to the exporter as well
+	if !known && len(current) > 0 {
+		g := e.metrics[key].WithLabelValues(append(labels, current)...)
+		g.Set(1)
+		g.Collect(ch)
+	}
+}
+
 // NormalizeKey replace invalid chars to underscores
 func (e *nutanixExporter) normalizeKey(key string) string {
 	key = strings.Replace(key, ".", "_", -1)
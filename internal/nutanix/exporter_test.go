@@ -0,0 +1,30 @@
+package nutanix
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectStateSet(t *testing.T) {
+	e := &nutanixExporter{metrics: make(map[string]*prometheus.GaugeVec), namespace: "test"}
+	e.metrics["state"] = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "state"}, []string{"uuid", "state"})
+
+	states := []string{"NORMAL", "DETACHABLE"}
+
+	ch := make(chan prometheus.Metric, 10)
+	e.collectStateSet(ch, "state", []string{"host1"}, states, "NORMAL")
+	close(ch)
+	assert.Len(t, ch, 2)
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.metrics["state"].WithLabelValues("host1", "NORMAL")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(e.metrics["state"].WithLabelValues("host1", "DETACHABLE")))
+
+	// Unknown states are published in addition to the known ones
+	ch = make(chan prometheus.Metric, 10)
+	e.collectStateSet(ch, "state", []string{"host2"}, states, "NEW_STATE")
+	close(ch)
+	assert.Len(t, ch, 3)
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.metrics["state"].WithLabelValues("host2", "NEW_STATE")))
+}
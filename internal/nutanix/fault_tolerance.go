@@ -0,0 +1,173 @@
+package nutanix
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	METRIC_FT_TOLERABLE         = "failures_tolerable"
+	METRIC_FT_RF_TOLERABLE      = "redundancy_factor_failures_tolerable"
+	METRIC_FT_REDUNDANCY_FACTOR = "redundancy_factor"
+	FT_DEFAULT_DOMAIN_TYPE      = "NODE"
+)
+
+// ftDomainLevels orders the fault tolerance domain types from the smallest to
+// the largest domain. A cluster configured for a domain type is expected to
+// tolerate the failures of its redundancy factor for every smaller domain type
+// as well.
+var ftDomainLevels = map[string]int{
+	"DISK":          0,
+	"NODE":          1,
+	"RACKABLE_UNIT": 2,
+	"RACK":          3,
+}
+
+// ftDomainLabels maps Prism domain types to label values
+var ftDomainLabels = map[string]string{
+	"DISK":          "disk",
+	"NODE":          "node",
+	"RACKABLE_UNIT": "block",
+	"RACK":          "rack",
+}
+
+// FaultToleranceExporter
+type FaultToleranceExporter struct {
+	*nutanixExporter
+	domains []interface{}
+}
+
+func (e *FaultToleranceExporter) domainLabel(domainType string) string {
+	if label, ok := ftDomainLabels[domainType]; ok {
+		return label
+	}
+	return strings.ToLower(domainType)
+}
+
+// Describe - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *FaultToleranceExporter) Describe(ch chan<- *prometheus.Desc) {
+	resp, err := e.api.makeV2Request("GET", "/cluster/", nil)
+	if err != nil {
+		e.result = nil
+		e.api.Logger().Error("Cluster discovery for fault tolerance failed")
+		return
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&e.result); err != nil {
+		e.result = nil
+		e.api.Logger().Error("Failed to decode cluster response")
+		return
+	}
+
+	resp, err = e.api.makeV1Request("GET", "/cluster/domain_fault_tolerance_status", nil)
+	if err != nil {
+		e.result = nil
+		e.api.Logger().Error("Fault tolerance discovery failed")
+		return
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&e.domains); err != nil {
+		e.result = nil
+		e.api.Logger().Error("Failed to decode fault tolerance response")
+		return
+	}
+
+	e.metrics[METRIC_FT_TOLERABLE] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_FT_TOLERABLE,
+		Help:      "Number of failures the component can currently tolerate per fault domain type"}, []string{"cluster_uuid", "domain_type", "component"})
+	e.metrics[METRIC_FT_TOLERABLE].Describe(ch)
+
+	e.metrics[METRIC_FT_RF_TOLERABLE] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_FT_RF_TOLERABLE,
+		Help:      "Failures to tolerate per fault domain type derived from the desired redundancy factor (RF - 1) and the configured fault tolerance domain, Prism does not report a desired level"}, []string{"cluster_uuid", "domain_type"})
+	e.metrics[METRIC_FT_RF_TOLERABLE].Describe(ch)
+
+	e.metrics[METRIC_FT_REDUNDANCY_FACTOR] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_FT_REDUNDANCY_FACTOR,
+		Help:      "Current and desired redundancy factor of the cluster"}, []string{"cluster_uuid", "state"})
+	e.metrics[METRIC_FT_REDUNDANCY_FACTOR].Describe(ch)
+}
+
+// Collect - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *FaultToleranceExporter) Collect(ch chan<- prometheus.Metric) {
+	if e.result == nil {
+		return
+	}
+	clusterUUID, _ := e.result["uuid"].(string)
+
+	var currentRF, desiredRF float64 = 0, 0
+	if state, ok := e.result["cluster_redundancy_state"].(map[string]interface{}); ok {
+		currentRF = e.valueToFloat64(state["current_redundancy_factor"])
+		desiredRF = e.valueToFloat64(state["desired_redundancy_factor"])
+	}
+	if desiredRF > 0 {
+		g := e.metrics[METRIC_FT_REDUNDANCY_FACTOR].WithLabelValues(clusterUUID, "current")
+		g.Set(currentRF)
+		g.Collect(ch)
+		g = e.metrics[METRIC_FT_REDUNDANCY_FACTOR].WithLabelValues(clusterUUID, "desired")
+		g.Set(desiredRF)
+		g.Collect(ch)
+	}
+
+	configuredDomain, ok := e.result["fault_tolerance_domain_type"].(string)
+	if !ok || len(configuredDomain) == 0 {
+		configuredDomain = FT_DEFAULT_DOMAIN_TYPE
+	}
+	configuredLevel, configuredKnown := ftDomainLevels[configuredDomain]
+	if !configuredKnown {
+		e.api.Logger().Warnf("Unknown fault tolerance domain type %s of cluster %s", configuredDomain, clusterUUID)
+	}
+
+	for _, domainRaw := range e.domains {
+		domain, ok := domainRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		domainType, _ := domain["domainType"].(string)
+		domainLabel := e.domainLabel(domainType)
+
+		// Failures derived from the desired redundancy factor, only expected
+		// for domains up to the configured domain type. Not published for
+		// domain types unknown to the exporter.
+		if level, known := ftDomainLevels[domainType]; known && configuredKnown && desiredRF > 0 {
+			var tolerable float64 = 0
+			if level <= configuredLevel {
+				tolerable = desiredRF - 1
+			}
+			g := e.metrics[METRIC_FT_RF_TOLERABLE].WithLabelValues(clusterUUID, domainLabel)
+			g.Set(tolerable)
+			g.Collect(ch)
+		}
+
+		components, _ := domain["componentFaultToleranceStatus"].(map[string]interface{})
+		for component, statusRaw := range components {
+			status, ok := statusRaw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			val := e.valueToFloat64(status["numberOfFailuresTolerable"])
+			g := e.metrics[METRIC_FT_TOLERABLE].WithLabelValues(clusterUUID, domainLabel, e.normalizeKey(component))
+			g.Set(val)
+			g.Collect(ch)
+		}
+	}
+	e.api.Logger().Debug("Fault tolerance data collected for cluster UUID : ", clusterUUID)
+}
+
+// NewFaultToleranceCollector
+func NewFaultToleranceCollector(_api *Nutanix) *FaultToleranceExporter {
+	return &FaultToleranceExporter{
+		nutanixExporter: &nutanixExporter{
+			api:       *_api,
+			metrics:   make(map[string]*prometheus.GaugeVec),
+			namespace: "nutanix_cluster_fault_tolerance",
+		},
+	}
+}
@@ -0,0 +1,73 @@
+package nutanix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultTolerance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PrismGateway/services/rest/v2.0/cluster/":
+			w.Write([]byte(`{"uuid": "c1", "fault_tolerance_domain_type": "RACKABLE_UNIT",
+				"cluster_redundancy_state": {"current_redundancy_factor": 2, "desired_redundancy_factor": 3}}`))
+		case "/PrismGateway/services/rest/v1/cluster/domain_fault_tolerance_status/":
+			w.Write([]byte(`[
+				{"domainType": "NODE", "componentFaultToleranceStatus": {
+					"EXTENT_GROUPS": {"numberOfFailuresTolerable": 1},
+					"ZOOKEEPER": {"numberOfFailuresTolerable": 2}}},
+				{"domainType": "RACK", "componentFaultToleranceStatus": {
+					"EXTENT_GROUPS": {"numberOfFailuresTolerable": 0}}},
+				{"domainType": "ZONE", "componentFaultToleranceStatus": {
+					"EXTENT_GROUPS": {"numberOfFailuresTolerable": 0}}}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	collector := NewFaultToleranceCollector(NewNutanix(server.URL, "user", "pass", 1))
+	expected := `
+# HELP nutanix_cluster_fault_tolerance_failures_tolerable Number of failures the component can currently tolerate per fault domain type
+# TYPE nutanix_cluster_fault_tolerance_failures_tolerable gauge
+nutanix_cluster_fault_tolerance_failures_tolerable{cluster_uuid="c1",component="extent_groups",domain_type="node"} 1
+nutanix_cluster_fault_tolerance_failures_tolerable{cluster_uuid="c1",component="extent_groups",domain_type="rack"} 0
+nutanix_cluster_fault_tolerance_failures_tolerable{cluster_uuid="c1",component="extent_groups",domain_type="zone"} 0
+nutanix_cluster_fault_tolerance_failures_tolerable{cluster_uuid="c1",component="zookeeper",domain_type="node"} 2
+# HELP nutanix_cluster_fault_tolerance_redundancy_factor Current and desired redundancy factor of the cluster
+# TYPE nutanix_cluster_fault_tolerance_redundancy_factor gauge
+nutanix_cluster_fault_tolerance_redundancy_factor{cluster_uuid="c1",state="current"} 2
+nutanix_cluster_fault_tolerance_redundancy_factor{cluster_uuid="c1",state="desired"} 3
+# HELP nutanix_cluster_fault_tolerance_redundancy_factor_failures_tolerable Failures to tolerate per fault domain type derived from the desired redundancy factor (RF - 1) and the configured fault tolerance domain, Prism does not report a desired level
+# TYPE nutanix_cluster_fault_tolerance_redundancy_factor_failures_tolerable gauge
+nutanix_cluster_fault_tolerance_redundancy_factor_failures_tolerable{cluster_uuid="c1",domain_type="node"} 2
+nutanix_cluster_fault_tolerance_redundancy_factor_failures_tolerable{cluster_uuid="c1",domain_type="rack"} 0
+`
+	// Domain types larger than the configured one are not expected to
+	// tolerate failures, unknown domain types get no derived level
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
+
+func TestFaultToleranceWithoutRedundancyState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PrismGateway/services/rest/v2.0/cluster/":
+			w.Write([]byte(`{"uuid": "c1"}`))
+		case "/PrismGateway/services/rest/v1/cluster/domain_fault_tolerance_status/":
+			w.Write([]byte(`[{"domainType": "NODE", "componentFaultToleranceStatus": {"OPLOG": {"numberOfFailuresTolerable": 1}}}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	collector := NewFaultToleranceCollector(NewNutanix(server.URL, "user", "pass", 1))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "nutanix_cluster_fault_tolerance_failures_tolerable"))
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, "nutanix_cluster_fault_tolerance_redundancy_factor_failures_tolerable"))
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, "nutanix_cluster_fault_tolerance_redundancy_factor"))
+}
@@ -0,0 +1,139 @@
+package nutanix
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// FilterRule matches an entity when all of its set conditions match
+type FilterRule struct {
+	UUID         string `yaml:"uuid"`
+	NameGlob     string `yaml:"name_glob"`
+	NameRegex    string `yaml:"name_regex"`
+	PowerState   string `yaml:"power_state"`
+	ControllerVM *bool  `yaml:"controller_vm"`
+
+	regex *regexp.Regexp
+}
+
+// EntityFilter selects the entities of a collector. An entity is kept when it
+// matches any include rule (or no include rules are configured) and no
+// exclude rule.
+type EntityFilter struct {
+	Include []FilterRule `yaml:"include"`
+	Exclude []FilterRule `yaml:"exclude"`
+}
+
+// filterKeys names the entity attributes the rules are applied to
+type filterKeys struct {
+	uuid, name, powerState, controllerVM string
+}
+
+var (
+	vmFilterKeys               = filterKeys{uuid: "uuid", name: "vmName", powerState: "powerState", controllerVM: "controllerVm"}
+	hostFilterKeys             = filterKeys{uuid: "uuid", name: "name"}
+	storageContainerFilterKeys = filterKeys{uuid: "storage_container_uuid", name: "name"}
+)
+
+// Compile validates the rules and compiles the regular expressions
+func (f *EntityFilter) Compile() error {
+	if f == nil {
+		return nil
+	}
+	for _, rules := range [][]FilterRule{f.Include, f.Exclude} {
+		for i := range rules {
+			rule := &rules[i]
+			if len(rule.UUID) == 0 && len(rule.NameGlob) == 0 && len(rule.NameRegex) == 0 && len(rule.PowerState) == 0 && rule.ControllerVM == nil {
+				return fmt.Errorf("filter rule without condition")
+			}
+			if len(rule.NameGlob) > 0 {
+				if _, err := path.Match(rule.NameGlob, ""); err != nil {
+					return fmt.Errorf("invalid name_glob %q: %w", rule.NameGlob, err)
+				}
+			}
+			if len(rule.NameRegex) > 0 {
+				regex, err := regexp.Compile(rule.NameRegex)
+				if err != nil {
+					return fmt.Errorf("invalid name_regex %q: %w", rule.NameRegex, err)
+				}
+				rule.regex = regex
+			}
+		}
+	}
+	return nil
+}
+
+func (r *FilterRule) match(ent map[string]interface{}, keys filterKeys) bool {
+	name, _ := ent[keys.name].(string)
+	if len(r.UUID) > 0 {
+		if uuid, _ := ent[keys.uuid].(string); uuid != r.UUID {
+			return false
+		}
+	}
+	if len(r.NameGlob) > 0 {
+		if ok, _ := path.Match(r.NameGlob, name); !ok {
+			return false
+		}
+	}
+	if r.regex != nil && !r.regex.MatchString(name) {
+		return false
+	}
+	if len(r.PowerState) > 0 {
+		if len(keys.powerState) == 0 {
+			return false
+		}
+		if state, _ := ent[keys.powerState].(string); !strings.EqualFold(state, r.PowerState) {
+			return false
+		}
+	}
+	if r.ControllerVM != nil {
+		if len(keys.controllerVM) == 0 {
+			return false
+		}
+		if cvm, _ := ent[keys.controllerVM].(bool); cvm != *r.ControllerVM {
+			return false
+		}
+	}
+	return true
+}
+
+// Match returns true if the entity is kept by the filter
+func (f *EntityFilter) Match(ent map[string]interface{}, keys filterKeys) bool {
+	if f == nil {
+		return true
+	}
+	included := len(f.Include) == 0
+	for i := range f.Include {
+		if f.Include[i].match(ent, keys) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for i := range f.Exclude {
+		if f.Exclude[i].match(ent, keys) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterEntities returns the entities kept by the filter
+func (f *EntityFilter) filterEntities(entities []interface{}, keys filterKeys) []interface{} {
+	if f == nil {
+		return entities
+	}
+	filtered := make([]interface{}, 0, len(entities))
+	for _, entRaw := range entities {
+		ent, ok := entRaw.(map[string]interface{})
+		if !ok || !f.Match(ent, keys) {
+			continue
+		}
+		filtered = append(filtered, entRaw)
+	}
+	return filtered
+}
@@ -0,0 +1,45 @@
+package nutanix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntityFilter(t *testing.T) {
+	cvm := true
+	filter := &EntityFilter{
+		Include: []FilterRule{{NameGlob: "prod-*"}, {NameRegex: "^db-[0-9]+$"}},
+		Exclude: []FilterRule{{PowerState: "off"}, {ControllerVM: &cvm}, {UUID: "excluded"}},
+	}
+	require.NoError(t, filter.Compile())
+
+	vms := []interface{}{
+		map[string]interface{}{"uuid": "1", "vmName": "prod-web", "powerState": "on", "controllerVm": false},
+		map[string]interface{}{"uuid": "2", "vmName": "db-01", "powerState": "on", "controllerVm": false},
+		map[string]interface{}{"uuid": "3", "vmName": "db-primary", "powerState": "on", "controllerVm": false},
+		map[string]interface{}{"uuid": "4", "vmName": "prod-old", "powerState": "off", "controllerVm": false},
+		map[string]interface{}{"uuid": "5", "vmName": "prod-cvm", "powerState": "on", "controllerVm": true},
+		map[string]interface{}{"uuid": "excluded", "vmName": "prod-api", "powerState": "on", "controllerVm": false},
+	}
+	kept := filter.filterEntities(vms, vmFilterKeys)
+	require.Len(t, kept, 2)
+	assert.Equal(t, "1", kept[0].(map[string]interface{})["uuid"])
+	assert.Equal(t, "2", kept[1].(map[string]interface{})["uuid"])
+
+	// VM only conditions never match hosts
+	host := map[string]interface{}{"uuid": "h1", "name": "prod-host"}
+	assert.True(t, filter.Match(host, hostFilterKeys))
+
+	// No filter keeps everything
+	var none *EntityFilter
+	assert.Len(t, none.filterEntities(vms, vmFilterKeys), len(vms))
+}
+
+func TestEntityFilterCompile(t *testing.T) {
+	assert.Error(t, (&EntityFilter{Include: []FilterRule{{NameRegex: "("}}}).Compile())
+	assert.Error(t, (&EntityFilter{Exclude: []FilterRule{{NameGlob: "["}}}).Compile())
+	assert.Error(t, (&EntityFilter{Exclude: []FilterRule{{}}}).Compile())
+	assert.NoError(t, (&EntityFilter{}).Compile())
+}
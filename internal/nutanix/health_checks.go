@@ -0,0 +1,154 @@
+package nutanix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	METRIC_HC_ENABLED  = "enabled"
+	METRIC_HC_ENTITIES = "entities"
+)
+
+// healthCheckStatuses maps Prism health status names to the status label
+var healthCheckStatuses = map[string]string{
+	"Good":     "pass",
+	"Warning":  "warn",
+	"Critical": "fail",
+	"Error":    "error",
+}
+
+// healthCheckEntityTypes maps the v1 entity collections providing a health
+// check summary to the singular entity_type label, as used by the events
+var healthCheckEntityTypes = []struct {
+	collection, label string
+}{
+	{"cluster", "cluster"},
+	{"hosts", "host"},
+	{"vms", "vm"},
+	{"disks", "disk"},
+	{"containers", "storage_container"},
+	{"storage_pools", "storage_pool"},
+}
+
+// HealthChecksExporter
+type HealthChecksExporter struct {
+	*nutanixExporter
+	checks    []interface{}
+	summaries map[string]map[string]interface{} // entity type -> summary
+}
+
+// Describe - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *HealthChecksExporter) Describe(ch chan<- *prometheus.Desc) {
+	resp, err := e.api.makeV1Request("GET", "/health_checks", nil)
+	if err != nil {
+		e.checks = nil
+		e.api.Logger().Error("Health check discovery failed")
+		return
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&e.checks); err != nil {
+		e.checks = nil
+		e.api.Logger().Error("Failed to decode health checks response")
+		return
+	}
+
+	// Per check health summary of each entity type
+	e.summaries = make(map[string]map[string]interface{})
+	for _, entityType := range healthCheckEntityTypes {
+		params := url.Values{}
+		params.Set("detailedSummary", "true")
+		resp, err := e.api.makeV1Request("GET", fmt.Sprintf("/%s/health_check_summary", entityType.collection), params)
+		if err != nil {
+			e.api.Logger().Errorf("Health check summary discovery failed for %s", entityType.collection)
+			continue
+		}
+		var summary map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&summary)
+		resp.Body.Close()
+		if err != nil {
+			e.api.Logger().Errorf("Failed to decode health check summary response for %s", entityType.collection)
+			continue
+		}
+		e.summaries[entityType.label] = summary
+	}
+
+	e.metrics[METRIC_HC_ENABLED] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_HC_ENABLED,
+		Help:      "Health check is enabled"}, []string{"check_id", "name", "check_type", "affected_entity_types"})
+	e.metrics[METRIC_HC_ENABLED].Describe(ch)
+
+	e.metrics[METRIC_HC_ENTITIES] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_HC_ENTITIES,
+		Help:      "Count entities per health check result"}, []string{"check_id", "entity_type", "status"})
+	e.metrics[METRIC_HC_ENTITIES].Describe(ch)
+}
+
+// Collect - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *HealthChecksExporter) Collect(ch chan<- prometheus.Metric) {
+	if e.checks == nil {
+		return
+	}
+
+	for _, checkRaw := range e.checks {
+		check, ok := checkRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id := fmt.Sprintf("%v", check["id"])
+		name, _ := check["name"].(string)
+		checkType, _ := check["checkType"].(string)
+
+		entityTypes := []string{}
+		if obj, ok := check["affectedEntityTypes"].([]interface{}); ok {
+			for _, entityType := range obj {
+				entityTypes = append(entityTypes, strings.ToLower(fmt.Sprintf("%v", entityType)))
+			}
+		}
+
+		var enabled float64 = 0
+		if val, ok := check["enabled"].(bool); ok && val {
+			enabled = 1
+		}
+		g := e.metrics[METRIC_HC_ENABLED].WithLabelValues(id, name, checkType, strings.Join(entityTypes, ","))
+		g.Set(enabled)
+		g.Collect(ch)
+	}
+
+	// detailedCheckSummary holds the results per check id as
+	// {"<check id>": {"Good": n, "Warning": n, "Critical": n, "Error": n}}
+	for entityType, summary := range e.summaries {
+		checkSummaries, _ := summary["detailedCheckSummary"].(map[string]interface{})
+		for checkID, checkRaw := range checkSummaries {
+			checkSummary, ok := checkRaw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for prismStatus, status := range healthCheckStatuses {
+				g := e.metrics[METRIC_HC_ENTITIES].WithLabelValues(checkID, entityType, status)
+				g.Set(e.valueToFloat64(checkSummary[prismStatus]))
+				g.Collect(ch)
+			}
+		}
+	}
+	e.api.Logger().Debugf("Health check data collected for %d checks", len(e.checks))
+}
+
+// NewHealthChecksCollector
+func NewHealthChecksCollector(_api *Nutanix) *HealthChecksExporter {
+	return &HealthChecksExporter{
+		nutanixExporter: &nutanixExporter{
+			api:       *_api,
+			metrics:   make(map[string]*prometheus.GaugeVec),
+			namespace: "nutanix_health_checks",
+		},
+	}
+}
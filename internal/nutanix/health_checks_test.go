@@ -0,0 +1,46 @@
+package nutanix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthChecks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PrismGateway/services/rest/v1/health_checks/":
+			w.Write([]byte(`[
+				{"id": "6008", "name": "CVM memory check", "checkType": "kScheduled", "affectedEntityTypes": ["VM"], "enabled": true},
+				{"id": "101001", "name": "Disk usage check", "checkType": "kScheduled", "affectedEntityTypes": ["DISK", "HOST"], "enabled": false}]`))
+		case "/PrismGateway/services/rest/v1/vms/health_check_summary/":
+			assert.Equal(t, "true", r.URL.Query().Get("detailedSummary"))
+			w.Write([]byte(`{"detailedCheckSummary": {"6008": {"Good": 20, "Warning": 2, "Critical": 1}}}`))
+		case "/PrismGateway/services/rest/v1/containers/health_check_summary/":
+			w.Write([]byte(`{"detailedCheckSummary": {}}`))
+		default:
+			// Summaries failing for an entity type are skipped
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	collector := NewHealthChecksCollector(NewNutanix(server.URL, "user", "pass", 1))
+	expected := `
+# HELP nutanix_health_checks_enabled Health check is enabled
+# TYPE nutanix_health_checks_enabled gauge
+nutanix_health_checks_enabled{affected_entity_types="disk,host",check_id="101001",check_type="kScheduled",name="Disk usage check"} 0
+nutanix_health_checks_enabled{affected_entity_types="vm",check_id="6008",check_type="kScheduled",name="CVM memory check"} 1
+# HELP nutanix_health_checks_entities Count entities per health check result
+# TYPE nutanix_health_checks_entities gauge
+nutanix_health_checks_entities{check_id="6008",entity_type="vm",status="error"} 0
+nutanix_health_checks_entities{check_id="6008",entity_type="vm",status="fail"} 1
+nutanix_health_checks_entities{check_id="6008",entity_type="vm",status="pass"} 20
+nutanix_health_checks_entities{check_id="6008",entity_type="vm",status="warn"} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
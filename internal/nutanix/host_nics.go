@@ -6,7 +6,6 @@ import (
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
 const KEY_HOST_NIC_PROPERTIES = "properties"
@@ -23,20 +22,20 @@ func (e *HostNicsExporter) Describe(ch chan<- *prometheus.Desc) {
 
 	// Construct the NIC endpoint using the single host UUID
 	nicEndpoint := fmt.Sprintf("/hosts/%s/host_nics", uuid)
-	log.Debug("Host Nic Endpoint: " + nicEndpoint)
+	e.api.Logger().Debug("Host Nic Endpoint: " + nicEndpoint)
 
 	// Make the API request to fetch host NICs information (no paging)
 	resp, err := e.api.makeV2Request("GET", nicEndpoint, nil)
 	if err != nil {
 		e.result = nil
-		log.Error("Host nic discovery failed")
+		e.api.Logger().Error("Host nic discovery failed")
 		return
 	}
 
 	var entities []interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&entities); err != nil {
 		e.result = nil
-		log.Error("Failed to decode host NICs response")
+		e.api.Logger().Error("Failed to decode host NICs response")
 		return
 	}
 
@@ -57,23 +56,19 @@ func (e *HostNicsExporter) Describe(ch chan<- *prometheus.Desc) {
 
 		// Publish host properties as separate record
 		key := KEY_HOST_NIC_PROPERTIES
-		e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: e.namespace,
-			Name:      key, Help: "..."}, e.properties)
-		e.metrics[key].Describe(ch)
+		e.describeProperties(ch, key, e.properties)
+		e.describeIPAddresses(ch, []string{"uuid", "node_uuid"})
+		for _, key := range e.allFields() {
+			e.describeStat(ch, key, []string{"uuid", "node_uuid"})
+		}
 
 		if stats != nil {
 			for key := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
-				key = e.normalizeKey(key)
-				e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-					Namespace: e.namespace,
-					Name:      key, Help: "..."}, []string{"uuid", "node_uuid"})
-
-				e.metrics[key].Describe(ch)
+				e.describeStat(ch, key, []string{"uuid", "node_uuid"})
 			}
 		}
 	}
@@ -127,13 +122,12 @@ func (e *HostNicsExporter) Collect(ch chan<- prometheus.Metric) {
 			}
 			property_values = append(property_values, val)
 		}
-		g := e.metrics[key].WithLabelValues(property_values...)
-		g.Set(1)
-		g.Collect(ch)
+		e.collectProperties(ch, key, property_values)
+		e.collectIPAddresses(ch, ent["ipv4_addresses"], ent["uuid"].(string), ent["node_uuid"].(string))
 
 		if stats != nil {
 			for key, value := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
@@ -142,18 +136,13 @@ func (e *HostNicsExporter) Collect(ch chan<- prometheus.Metric) {
 				if val == -1 {
 					continue
 				}
-				key = e.normalizeKey(key)
-				g := e.metrics[key].WithLabelValues(ent["uuid"].(string), ent["node_uuid"].(string))
-				g.Set(val)
-				g.Collect(ch)
+				e.collectStat(ch, key, val, ent["uuid"].(string), ent["node_uuid"].(string))
 			}
 		}
-		for _, key := range e.fields {
-			g := e.metrics[key].WithLabelValues(ent["uuid"].(string), ent["node_uuid"].(string))
-			g.Set(e.valueToFloat64(ent[key]))
-			g.Collect(ch)
+		for _, key := range e.allFields() {
+			e.collectStat(ch, key, e.valueToFloat64(ent[key]), ent["uuid"].(string), ent["node_uuid"].(string))
 		}
-		log.Debugf("Host NIC data collected for host: %s (UUID: %s)", e.HostName, e.HostUUID)
+		e.api.Logger().Debugf("Host NIC data collected for host: %s (UUID: %s)", e.HostName, e.HostUUID)
 	}
 }
 
@@ -163,10 +152,12 @@ func NewHostsNetworkCollector(_api *Nutanix, hostname string, hostuuid string) *
 		HostName: hostname,
 		HostUUID: hostuuid,
 		nutanixExporter: &nutanixExporter{
-			api:        *_api,
-			metrics:    make(map[string]*prometheus.GaugeVec),
-			namespace:  "nutanix_hostnics",
-			properties: []string{"node_uuid", "uuid", "hostname", "mac_address", "ipv4_addresses", "name", "mtu_in_bytes"},
+			api:                *_api,
+			metrics:            make(map[string]*prometheus.GaugeVec),
+			namespace:          "nutanix_hostnics",
+			properties:         []string{"node_uuid", "uuid", "hostname", "mac_address", "ipv4_addresses", "name", "mtu_in_bytes"},
+			volatileProperties: map[string]bool{"ipv4_addresses": true, "mtu_in_bytes": true},
+			volatileFields:     []string{"mtu_in_bytes"},
 			filter_stats: map[string]bool{
 				"network.received_bytes":         true,
 				"network.received_pkts":          true,
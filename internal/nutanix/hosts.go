@@ -10,25 +10,100 @@
 package nutanix
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
 const (
-	KEY_HOST_PROPERTIES = "properties"
-	METRIC_HA_RESERVED  = "ha_memory_reserved_bytes"
+	KEY_HOST_PROPERTIES         = "properties"
+	KEY_HOST_HYPERVISOR_INFO    = "hypervisor_info"
+	METRIC_HA_RESERVED          = "ha_memory_reserved_bytes"
+	METRIC_HOST_MAINTENANCE     = "maintenance_mode"
+	METRIC_HOST_DEGRADED        = "is_degraded"
+	METRIC_HOST_STATE           = "state"
+	METRIC_HOST_HYPERVISOR      = "hypervisor_state"
+	METRIC_HOST_MAINT_TARGET    = "maintenance_mode_target"
+	METRIC_HOST_METADATA_STATUS = "metadata_store_status"
+	METRIC_HOST_CVM_STATE       = "cvm_state"
 )
 
+// hostStates lists the known values of the enumerated host states
+var hostStates = map[string][]string{
+	METRIC_HOST_STATE:           {"NORMAL", "MARKED_FOR_REMOVAL_BUT_NOT_DETACHABLE", "DETACHABLE"},
+	METRIC_HOST_HYPERVISOR:      {"kAcropolisNormal", "kEnteringMaintenanceMode", "kEnteredMaintenanceMode", "kReservedMaintenanceMode", "kEnteringMaintenanceModeFromHAFailover", "kEnteredMaintenanceModeFromHAFailover", "kReservingMaintenanceModeForHAFailover", "kHAFailoverSource", "kHAFailoverTarget", "kHAHealing", "kAcropolisUnavailable"},
+	METRIC_HOST_MAINT_TARGET:    {"none", "kReserved", "kEnteredMaintenanceMode"},
+	METRIC_HOST_METADATA_STATUS: {"kNormalMode", "kReadOnlyMode", "kToBeRemoved", "kToBeDetached", "kDetachedFromRing", "kAddingToRing"},
+	METRIC_HOST_CVM_STATE:       {"up", "down"},
+}
+
 // HostsExporter
 type HostsExporter struct {
 	*nutanixExporter
 	networkExporters map[string]*HostNicsExporter
 	collecthostnics  bool
+	nicConfig        *CollectorConfig
 	haEntities       map[string]float64 // id -> {memory_size, ha_reserved}
+	cvmStates        map[string]string  // host uuid -> cvm state
+	vms              *VmsExporter
+}
+
+// ConfigureNics sets the collector config applied to the per host NIC
+// collectors
+func (e *HostsExporter) ConfigureNics(conf *CollectorConfig) {
+	e.nicConfig = conf
+}
+
+// controllerVms returns the controller VMs of a v1 VM list
+func controllerVms(entities []interface{}) []interface{} {
+	cvms := []interface{}{}
+	for _, entRaw := range entities {
+		ent := entRaw.(map[string]interface{})
+		if cvm, ok := ent["controllerVm"].(bool); ok && cvm {
+			cvms = append(cvms, ent)
+		}
+	}
+	return cvms
+}
+
+// fetchCvmStates loads the power state of the controller VM of each host. The
+// VMs already discovered by the VmsExporter are reused, v1 has no filter for
+// the controller VMs.
+func (e *HostsExporter) fetchCvmStates() {
+	var entities []interface{}
+	if e.vms != nil {
+		if e.vms.controllerVms == nil {
+			e.cvmStates = nil
+			e.api.Logger().Debugf("No controller VMs discovered for hosts")
+			return
+		}
+		entities = e.vms.controllerVms
+	} else {
+		vms, err := e.api.fetchAllPagesV1("/vms", nil)
+		if err != nil {
+			e.cvmStates = nil
+			e.api.Logger().Errorf("Controller VM discovery failed: %v", err)
+			return
+		}
+		entities = controllerVms(vms)
+	}
+
+	e.cvmStates = make(map[string]string)
+	for _, entRaw := range entities {
+		ent := entRaw.(map[string]interface{})
+		hostUUID, ok := ent["hostUuid"].(string)
+		if !ok {
+			continue
+		}
+		state := "down"
+		if ent["powerState"] == "on" {
+			state = "up"
+		}
+		e.cvmStates[hostUUID] = state
+	}
 }
 
 func (e *HostsExporter) fetchHaEntities(cluster_uid string) {
@@ -36,7 +111,7 @@ func (e *HostsExporter) fetchHaEntities(cluster_uid string) {
 	url := "/utils/entities?entityType=host&projection=ha_memory_reserved_bytes&proxyClusterUuid=" + cluster_uid
 	entities, err := e.api.fetchAllPagesV1(url, nil)
 	if err != nil {
-		log.Errorf("HA entities fetch failed: %v", err)
+		e.api.Logger().Errorf("HA entities fetch failed: %v", err)
 		return
 	}
 
@@ -47,7 +122,7 @@ func (e *HostsExporter) fetchHaEntities(cluster_uid string) {
 		hostID := ent["id"].(string)
 		e.haEntities[hostID] = e.valueToFloat64(ent["ha_memory_reserved_bytes"])
 	}
-	log.Infof("HA entities loaded for %d hosts", len(e.haEntities))
+	e.api.Logger().Infof("HA entities loaded for %d hosts", len(e.haEntities))
 }
 
 func (e *HostsExporter) getHaReserved(hostID string) float64 {
@@ -62,16 +137,18 @@ func (e *HostsExporter) getHaReserved(hostID string) float64 {
 func (e *HostsExporter) Describe(ch chan<- *prometheus.Desc) {
 	uuid, err := e.api.GetClusterUUID()
 	if err != nil {
-		log.Error("failed to get cluster uuid skipping ha metrics cal")
+		e.api.Logger().Error("failed to get cluster uuid skipping ha metrics cal")
 
 	}
 	e.fetchHaEntities(uuid)
+	e.fetchCvmStates()
 	entities, err := e.api.fetchAllPages("/hosts", nil)
 	if err != nil {
 		e.result = nil
-		log.Error("Host discovery failed")
+		e.api.Logger().Error("Host discovery failed")
 		return
 	}
+	entities = e.filter.filterEntities(entities, hostFilterKeys)
 
 	e.result = map[string]interface{}{"entities": entities}
 
@@ -79,6 +156,11 @@ func (e *HostsExporter) Describe(ch chan<- *prometheus.Desc) {
 		return
 	}
 
+	if e.time_series != nil {
+		e.fetchTimeSeries("hosts", entityUUIDs(entities))
+		e.describeTimeSeries(ch, []string{"uuid", "cluster_uuid"})
+	}
+
 	for _, entRaw := range entities {
 		ent := entRaw.(map[string]interface{})
 		var stats, usageStats map[string]interface{} = nil, nil
@@ -92,10 +174,10 @@ func (e *HostsExporter) Describe(ch chan<- *prometheus.Desc) {
 
 		// Publish host properties as separate record
 		key := KEY_HOST_PROPERTIES
-		e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: e.namespace,
-			Name:      key, Help: "..."}, e.properties)
-		e.metrics[key].Describe(ch)
+		e.describeProperties(ch, key, e.properties)
+		if e.naming == METRIC_NAMING_V2 {
+			e.describeStat(ch, KEY_HOST_HYPERVISOR_INFO, []string{"uuid", "cluster_uuid", "hypervisor_full_name"})
+		}
 
 		if e.collecthostnics {
 			var hostName string
@@ -105,48 +187,43 @@ func (e *HostsExporter) Describe(ch chan<- *prometheus.Desc) {
 			if obj, ok := ent["uuid"]; ok {
 				uuid := obj.(string)
 				e.networkExporters[uuid] = NewHostsNetworkCollector(&e.api, hostName, uuid)
+				e.networkExporters[uuid].Configure(e.nicConfig)
+				e.networkExporters[uuid].SetMetricNaming(e.naming)
 			}
 		}
 
 		if usageStats != nil {
 			for key := range usageStats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
-				key = e.normalizeKey(key)
-
-				e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-					Namespace: e.namespace,
-					Name:      key, Help: "..."}, []string{"uuid", "cluster_uuid"})
-
-				e.metrics[key].Describe(ch)
+				e.describeStat(ch, key, []string{"uuid", "cluster_uuid"})
 			}
 		}
 		if stats != nil {
 			e.addCalculatedStats(ent, stats)
 			for key := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
-				key = e.normalizeKey(key)
-				e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-					Namespace: e.namespace,
-					Name:      key, Help: "..."}, []string{"uuid", "cluster_uuid"})
-
-				e.metrics[key].Describe(ch)
+				e.describeStat(ch, key, []string{"uuid", "cluster_uuid"})
 			}
 		}
-		for _, key := range e.fields {
-			e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-				Namespace: e.namespace,
-				Name:      key, Help: "..."}, []string{"uuid", "cluster_uuid"})
-			e.metrics[key].Describe(ch)
+		for _, key := range e.allFields() {
+			e.describeStat(ch, key, []string{"uuid", "cluster_uuid"})
 		}
 
 	}
 
+	for _, key := range []string{METRIC_HOST_MAINTENANCE, METRIC_HOST_DEGRADED} {
+		e.describeStat(ch, key, []string{"uuid", "cluster_uuid"})
+	}
+	for key := range hostStates {
+		e.describeStat(ch, key, []string{"uuid", "cluster_uuid", "state"})
+	}
+
 	e.DescribeNicsParallel(ch)
 }
 
@@ -176,7 +253,7 @@ func (e *HostsExporter) addCalculatedStats(ent map[string]interface{}, stats map
 	// ---------- Extract HA host ID ----------
 	vmid, ok := ent["service_vmid"].(string)
 	if !ok || !strings.Contains(vmid, "::") {
-		log.Warnf("Invalid service_vmid for host %v", ent["uuid"])
+		e.api.Logger().Warnf("Invalid service_vmid for host %v", ent["uuid"])
 		return
 	}
 	hostID := strings.Split(vmid, "::")[1]
@@ -205,7 +282,7 @@ func (e *HostsExporter) addCalculatedStats(ent map[string]interface{}, stats map
 	stats[METRIC_MEM_FREE_BYTES] = memFree
 	stats[METRIC_HA_RESERVED] = haReserved
 
-	log.Debugf(
+	e.api.Logger().Debugf(
 		"Host %s memory: total=%.1fGB used=%.1fGB free=%.1fGB ha=%.1fGB",
 		hostID,
 		mem_total/1024/1024/1024,
@@ -272,18 +349,20 @@ func (e *HostsExporter) Collect(ch chan<- prometheus.Metric) {
 			default:
 				obj := ent[property]
 				if obj != nil {
-					val = ent[property].(string)
+					val = fmt.Sprintf("%v", obj)
 				}
 			}
 			property_values = append(property_values, val)
 		}
-		g := e.metrics[key].WithLabelValues(property_values...)
-		g.Set(1)
-		g.Collect(ch)
+		e.collectProperties(ch, key, property_values)
+		if e.naming == METRIC_NAMING_V2 {
+			hypervisor, _ := ent["hypervisor_full_name"].(string)
+			e.collectStat(ch, KEY_HOST_HYPERVISOR_INFO, 1, ent["uuid"].(string), ent["cluster_uuid"].(string), hypervisor)
+		}
 
 		if usageStats != nil {
 			for key, value := range usageStats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
@@ -292,15 +371,12 @@ func (e *HostsExporter) Collect(ch chan<- prometheus.Metric) {
 				if val == -1 {
 					continue
 				}
-				key = e.normalizeKey(key)
-				g := e.metrics[key].WithLabelValues(ent["uuid"].(string), ent["cluster_uuid"].(string))
-				g.Set(val)
-				g.Collect(ch)
+				e.collectStat(ch, key, val, ent["uuid"].(string), ent["cluster_uuid"].(string))
 			}
 		}
 		if stats != nil {
 			for key, value := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
@@ -309,29 +385,67 @@ func (e *HostsExporter) Collect(ch chan<- prometheus.Metric) {
 				if val == -1 {
 					continue
 				}
-				key = e.normalizeKey(key)
-				g := e.metrics[key].WithLabelValues(ent["uuid"].(string), ent["cluster_uuid"].(string))
-				g.Set(val)
-				g.Collect(ch)
+				e.collectStat(ch, key, val, ent["uuid"].(string), ent["cluster_uuid"].(string))
 			}
 		}
-		for _, key := range e.fields {
-			g := e.metrics[key].WithLabelValues(ent["uuid"].(string), ent["cluster_uuid"].(string))
-			g.Set(e.valueToFloat64(ent[key]))
-			g.Collect(ch)
+		for _, key := range e.allFields() {
+			e.collectStat(ch, key, e.valueToFloat64(ent[key]), ent["uuid"].(string), ent["cluster_uuid"].(string))
 		}
-		log.Debugf("Host data collected for host: UUID=%s, Name=%s", ent["uuid"], ent["name"])
+		e.collectTimeSeries(ch, ent["uuid"].(string), ent["uuid"].(string), ent["cluster_uuid"].(string))
+		e.collectAvailability(ch, ent)
+		e.api.Logger().Debugf("Host data collected for host: UUID=%s, Name=%s", ent["uuid"], ent["name"])
 	}
 
 	for hostUUID, networkExporter := range e.networkExporters {
-		log.Debugf("Collect nic metrics for host UUID: %s", hostUUID)
+		e.api.Logger().Debugf("Collect nic metrics for host UUID: %s", hostUUID)
 		networkExporter.Collect(ch)
 	}
 }
 
-// NewHostsCollector
-func NewHostsCollector(_api *Nutanix, collecthostnics bool) *HostsExporter {
+// collectAvailability publishes maintenance, degraded and the enumerated states of a host
+func (e *HostsExporter) collectAvailability(ch chan<- prometheus.Metric, ent map[string]interface{}) {
+	labels := []string{ent["uuid"].(string), ent["cluster_uuid"].(string)}
+
+	for key, property := range map[string]string{
+		METRIC_HOST_MAINTENANCE: "host_in_maintenance_mode",
+		METRIC_HOST_DEGRADED:    "is_degraded",
+	} {
+		var val float64 = 0
+		if b, ok := ent[property].(bool); ok && b {
+			val = 1
+		}
+		g := e.metrics[key].WithLabelValues(labels...)
+		g.Set(val)
+		g.Collect(ch)
+	}
+
+	for key, property := range map[string]string{
+		METRIC_HOST_STATE:           "state",
+		METRIC_HOST_HYPERVISOR:      "hypervisor_state",
+		METRIC_HOST_MAINT_TARGET:    "host_maintenance_mode_target",
+		METRIC_HOST_METADATA_STATUS: "metadata_store_status",
+	} {
+		current, _ := ent[property].(string)
+		if key == METRIC_HOST_MAINT_TARGET && len(current) == 0 {
+			current = "none"
+		}
+		e.collectStateSet(ch, key, labels, hostStates[key], current)
+	}
+
+	if e.cvmStates != nil {
+		current, ok := e.cvmStates[labels[0]]
+		if !ok {
+			current = "down"
+		}
+		e.collectStateSet(ch, METRIC_HOST_CVM_STATE, labels, hostStates[METRIC_HOST_CVM_STATE], current)
+	}
+}
+
+// NewHostsCollector - vms is optional and used to reuse the controller VMs
+// already discovered by the VmsExporter
+func NewHostsCollector(_api *Nutanix, collecthostnics bool, vms *VmsExporter) *HostsExporter {
 	return &HostsExporter{
+		vms:              vms,
 		networkExporters: make(map[string]*HostNicsExporter),
 		collecthostnics:  collecthostnics,
 		nutanixExporter: &nutanixExporter{
@@ -340,6 +454,8 @@ func NewHostsCollector(_api *Nutanix, collecthostnics bool) *HostsExporter {
 			namespace:  "nutanix_hosts",
 			fields:     []string{"num_vms", "num_cpu_cores", "num_cpu_sockets", "num_cpu_threads", "cpu_frequency_in_hz", "cpu_capacity_in_hz", "memory_capacity_in_bytes", "boot_time_in_usecs"},
 			properties: []string{"uuid", "cluster_uuid", "name", "host_type", "hypervisor_address", "serial", "hypervisor_full_name", "num_vms", "num_cpu_cores", "num_cpu_sockets", "num_cpu_threads", "cpu_frequency_in_mhz", "cpu_capacity_in_mhz", "memory_capacity_in_mb", "block_model_name"},
+			// The numeric properties are fields, the hypervisor changes on upgrades
+			volatileProperties: map[string]bool{"hypervisor_full_name": true, "num_vms": true, "num_cpu_cores": true, "num_cpu_sockets": true, "num_cpu_threads": true, "cpu_frequency_in_mhz": true, "cpu_capacity_in_mhz": true, "memory_capacity_in_mb": true},
 			filter_stats: map[string]bool{
 				"storage.capacity_bytes":                true,
 				"storage.usage_bytes":                   true,
@@ -375,7 +491,7 @@ func (e *HostsExporter) DescribeNicsParallel(ch chan<- *prometheus.Desc) {
 			defer wg.Done()
 			semaphore <- struct{}{}        // Acquire a token
 			defer func() { <-semaphore }() // Release the token
-			log.Debugf("Describing host nic metrics for host UUID: %s", hostUUID)
+			e.api.Logger().Debugf("Describing host nic metrics for host UUID: %s", hostUUID)
 			exporter.Describe(ch)
 		}(hostUUID, networkExporter)
 	}
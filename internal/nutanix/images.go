@@ -0,0 +1,127 @@
+package nutanix
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	KEY_IMAGE_PROPERTIES          = "properties"
+	METRIC_IMAGE_SIZE_BYTES       = "size_bytes"
+	METRIC_IMAGE_CREATED_TIME     = "created_time_in_usecs"
+	METRIC_IMAGE_CONTAINER_SIZE   = "storage_container_size_bytes"
+	METRIC_IMAGE_CONTAINER_IMAGES = "storage_container_images"
+)
+
+// ImagesExporter
+type ImagesExporter struct {
+	*nutanixExporter
+}
+
+// Describe - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *ImagesExporter) Describe(ch chan<- *prometheus.Desc) {
+	entities, err := e.api.fetchAllPages("/images", nil)
+	if err != nil {
+		e.result = nil
+		e.api.Logger().Error("Image discovery failed")
+		return
+	}
+
+	e.result = map[string]interface{}{"entities": entities}
+
+	key := KEY_IMAGE_PROPERTIES
+	e.describeStat(ch, key, e.properties)
+
+	for _, key := range e.fields {
+		e.describeStat(ch, key, []string{"uuid", "storage_container_uuid"})
+	}
+
+	e.metrics[METRIC_IMAGE_CONTAINER_SIZE] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_IMAGE_CONTAINER_SIZE,
+		Help:      "Total size of the image library per storage container"}, []string{"storage_container_uuid"})
+	e.metrics[METRIC_IMAGE_CONTAINER_SIZE].Describe(ch)
+
+	e.metrics[METRIC_IMAGE_CONTAINER_IMAGES] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_IMAGE_CONTAINER_IMAGES,
+		Help:      "Count images per storage container"}, []string{"storage_container_uuid"})
+	e.metrics[METRIC_IMAGE_CONTAINER_IMAGES].Describe(ch)
+}
+
+// Collect - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *ImagesExporter) Collect(ch chan<- prometheus.Metric) {
+	if e.result == nil {
+		return
+	}
+	var entities []interface{} = nil
+	if obj, ok := e.result["entities"]; ok {
+		entities = obj.([]interface{})
+	}
+	if entities == nil {
+		return
+	}
+
+	containerSize := make(map[string]float64)
+	containerImages := make(map[string]float64)
+
+	for _, entity := range entities {
+		ent := entity.(map[string]interface{})
+		uuid, _ := ent["uuid"].(string)
+		containerUUID, _ := ent["storage_container_uuid"].(string)
+
+		key := KEY_IMAGE_PROPERTIES
+		var property_values []string
+		for _, property := range e.properties {
+			var val string = ""
+			obj := ent[property]
+			if obj != nil {
+				val = fmt.Sprintf("%v", obj)
+			}
+			property_values = append(property_values, val)
+		}
+		g := e.metrics[key].WithLabelValues(property_values...)
+		g.Set(1)
+		g.Collect(ch)
+
+		size := e.valueToFloat64(ent["vm_disk_size"])
+		containerSize[containerUUID] += size
+		containerImages[containerUUID]++
+
+		for _, key := range e.fields {
+			switch key {
+			case METRIC_IMAGE_SIZE_BYTES:
+				e.collectStat(ch, key, size, uuid, containerUUID)
+			default:
+				e.collectStat(ch, key, e.valueToFloat64(ent[key]), uuid, containerUUID)
+			}
+		}
+		e.api.Logger().Debugf("Image data collected for image: %s (UUID: %s)", ent["name"], uuid)
+	}
+
+	for containerUUID, size := range containerSize {
+		g := e.metrics[METRIC_IMAGE_CONTAINER_SIZE].WithLabelValues(containerUUID)
+		g.Set(size)
+		g.Collect(ch)
+
+		g = e.metrics[METRIC_IMAGE_CONTAINER_IMAGES].WithLabelValues(containerUUID)
+		g.Set(containerImages[containerUUID])
+		g.Collect(ch)
+	}
+}
+
+// NewImagesCollector
+func NewImagesCollector(_api *Nutanix) *ImagesExporter {
+	return &ImagesExporter{
+		&nutanixExporter{
+			api:        *_api,
+			metrics:    make(map[string]*prometheus.GaugeVec),
+			namespace:  "nutanix_images",
+			fields:     []string{METRIC_IMAGE_SIZE_BYTES, METRIC_IMAGE_CREATED_TIME},
+			properties: []string{"uuid", "name", "image_type", "image_state", "storage_container_uuid", "vm_disk_id"},
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package nutanix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImages(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/PrismGateway/services/rest/v2.0/images/" {
+			http.NotFound(w, r)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		switch page {
+		case "1":
+			w.Write([]byte(`{"metadata": {"grand_total_entities": 3, "end_index": 2}, "entities": [
+				{"uuid": "img1", "name": "centos", "image_type": "DISK_IMAGE", "image_state": "ACTIVE",
+				 "storage_container_uuid": "c1", "vm_disk_id": "d1", "vm_disk_size": 1073741824, "created_time_in_usecs": 1700000000000000},
+				{"uuid": "img2", "name": "ubuntu", "image_type": "DISK_IMAGE", "image_state": "ACTIVE",
+				 "storage_container_uuid": "c1", "vm_disk_id": "d2", "vm_disk_size": "2147483648"}]}`))
+		default:
+			// Images being uploaded have no disk yet
+			w.Write([]byte(`{"metadata": {"grand_total_entities": 3, "end_index": 3}, "entities": [
+				{"uuid": "img3", "name": "upload", "image_type": "ISO_IMAGE", "image_state": "INACTIVE"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	collector := NewImagesCollector(NewNutanix(server.URL, "user", "pass", 2))
+	expected := `
+# HELP nutanix_images_created_time_in_usecs Creation time of the image
+# TYPE nutanix_images_created_time_in_usecs gauge
+nutanix_images_created_time_in_usecs{storage_container_uuid="",uuid="img3"} 0
+nutanix_images_created_time_in_usecs{storage_container_uuid="c1",uuid="img1"} 1.7e+15
+nutanix_images_created_time_in_usecs{storage_container_uuid="c1",uuid="img2"} 0
+# HELP nutanix_images_properties Properties of the entity as labels, always 1
+# TYPE nutanix_images_properties gauge
+nutanix_images_properties{image_state="ACTIVE",image_type="DISK_IMAGE",name="centos",storage_container_uuid="c1",uuid="img1",vm_disk_id="d1"} 1
+nutanix_images_properties{image_state="ACTIVE",image_type="DISK_IMAGE",name="ubuntu",storage_container_uuid="c1",uuid="img2",vm_disk_id="d2"} 1
+nutanix_images_properties{image_state="INACTIVE",image_type="ISO_IMAGE",name="upload",storage_container_uuid="",uuid="img3",vm_disk_id=""} 1
+# HELP nutanix_images_size_bytes Size of the image
+# TYPE nutanix_images_size_bytes gauge
+nutanix_images_size_bytes{storage_container_uuid="",uuid="img3"} 0
+nutanix_images_size_bytes{storage_container_uuid="c1",uuid="img1"} 1.073741824e+09
+nutanix_images_size_bytes{storage_container_uuid="c1",uuid="img2"} 2.147483648e+09
+# HELP nutanix_images_storage_container_images Count images per storage container
+# TYPE nutanix_images_storage_container_images gauge
+nutanix_images_storage_container_images{storage_container_uuid=""} 1
+nutanix_images_storage_container_images{storage_container_uuid="c1"} 2
+# HELP nutanix_images_storage_container_size_bytes Total size of the image library per storage container
+# TYPE nutanix_images_storage_container_size_bytes gauge
+nutanix_images_storage_container_size_bytes{storage_container_uuid=""} 0
+nutanix_images_storage_container_size_bytes{storage_container_uuid="c1"} 3.221225472e+09
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+	// Both pages are fetched
+	assert.Equal(t, []string{"1", "2"}, pages)
+}
+
+func TestImagesDiscoveryFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	collector := NewImagesCollector(NewNutanix(server.URL, "user", "pass", 2))
+	assert.Equal(t, 0, testutil.CollectAndCount(collector))
+}
@@ -0,0 +1,85 @@
+package nutanix
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	KEY_INFO            = "info"
+	KEY_IP_ADDRESS_INFO = "ip_address_info"
+)
+
+// Under the v2 naming the properties record is split into an *_info metric
+// holding the stable identity properties and separate metrics for the
+// volatile ones, so that e.g. an IP change or a live migration does not
+// create a new properties series.
+
+// infoIndexes returns the indexes of the properties published on the info metric
+func (e *nutanixExporter) infoIndexes() []int {
+	indexes := []int{}
+	for i, property := range e.properties {
+		if !e.volatileProperties[property] {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func pickValues(values []string, indexes []int) []string {
+	picked := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		picked = append(picked, values[i])
+	}
+	return picked
+}
+
+// describeProperties registers the properties record, labels are the label
+// names of the properties
+func (e *nutanixExporter) describeProperties(ch chan<- *prometheus.Desc, key string, labels []string) {
+	if e.naming == METRIC_NAMING_V2 {
+		e.describeStat(ch, KEY_INFO, pickValues(labels, e.infoIndexes()))
+		return
+	}
+	e.describeStat(ch, key, labels)
+}
+
+// collectProperties publishes the properties record of an entity
+func (e *nutanixExporter) collectProperties(ch chan<- prometheus.Metric, key string, values []string) {
+	if e.naming == METRIC_NAMING_V2 {
+		key = KEY_INFO
+		values = pickValues(values, e.infoIndexes())
+	}
+	g := e.metrics[e.metricName(key)].WithLabelValues(values...)
+	g.Set(1)
+	g.Collect(ch)
+}
+
+// allFields returns the fields of the collector, including the numeric
+// volatile properties published as separate metrics under the v2 naming
+func (e *nutanixExporter) allFields() []string {
+	if e.naming == METRIC_NAMING_V2 {
+		return appendUnique(append([]string{}, e.fields...), e.volatileFields...)
+	}
+	return e.fields
+}
+
+// describeIPAddresses registers the metric holding one series per IP address
+// of an entity
+func (e *nutanixExporter) describeIPAddresses(ch chan<- *prometheus.Desc, labels []string) {
+	if e.naming == METRIC_NAMING_V2 {
+		e.describeStat(ch, KEY_IP_ADDRESS_INFO, append(append([]string{}, labels...), "ip_address"))
+	}
+}
+
+// collectIPAddresses publishes the IP addresses of an entity
+func (e *nutanixExporter) collectIPAddresses(ch chan<- prometheus.Metric, addresses interface{}, labelValues ...string) {
+	if e.naming != METRIC_NAMING_V2 {
+		return
+	}
+	list, _ := addresses.([]interface{})
+	for _, addrRaw := range list {
+		if addr, ok := addrRaw.(string); ok {
+			e.collectStat(ch, KEY_IP_ADDRESS_INFO, 1, append(append([]string{}, labelValues...), addr)...)
+		}
+	}
+}
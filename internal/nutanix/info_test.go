@@ -0,0 +1,67 @@
+package nutanix
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectPropertiesInfo(t *testing.T) {
+	newExporter := func(naming string) *nutanixExporter {
+		e := &nutanixExporter{
+			metrics:            make(map[string]*prometheus.GaugeVec),
+			namespace:          "nutanix_vms",
+			properties:         []string{"uuid", "vmName", "hostUuid", "ipAddresses"},
+			volatileProperties: map[string]bool{"hostUuid": true, "ipAddresses": true},
+		}
+		e.SetMetricNaming(naming)
+		return e
+	}
+	values := []string{"u1", "vm01", "h1", "10.0.0.1"}
+
+	collect := func(e *nutanixExporter) []*dto.Metric {
+		descs := make(chan *prometheus.Desc, 10)
+		e.describeProperties(descs, "properties", e.properties)
+		e.describeIPAddresses(descs, []string{"uuid"})
+		close(descs)
+
+		ch := make(chan prometheus.Metric, 10)
+		e.collectProperties(ch, "properties", values)
+		e.collectIPAddresses(ch, []interface{}{"10.0.0.1", "10.0.0.2"}, "u1")
+		close(ch)
+		var metrics []*dto.Metric
+		for m := range ch {
+			var pb dto.Metric
+			require.NoError(t, m.Write(&pb))
+			metrics = append(metrics, &pb)
+		}
+		return metrics
+	}
+
+	// v1 keeps all properties on a single record
+	metrics := collect(newExporter(METRIC_NAMING_V1))
+	require.Len(t, metrics, 1)
+	assert.Len(t, metrics[0].Label, 4)
+
+	// v2 publishes the stable properties on the info metric and one series per IP address
+	metrics = collect(newExporter(METRIC_NAMING_V2))
+	require.Len(t, metrics, 3)
+	labels := map[string]string{}
+	for _, l := range metrics[0].Label {
+		labels[l.GetName()] = l.GetValue()
+	}
+	assert.Equal(t, map[string]string{"uuid": "u1", "vmName": "vm01"}, labels)
+	assert.Equal(t, float64(1), metrics[0].GetGauge().GetValue())
+	assert.Equal(t, "10.0.0.2", metrics[2].Label[0].GetValue())
+}
+
+func TestAllFields(t *testing.T) {
+	e := &nutanixExporter{fields: []string{"a", "b"}, volatileFields: []string{"b", "c"}}
+	assert.Equal(t, []string{"a", "b"}, e.allFields())
+	e.SetMetricNaming(METRIC_NAMING_V2)
+	assert.Equal(t, []string{"a", "b", "c"}, e.allFields())
+	assert.Equal(t, []string{"a", "b"}, e.fields)
+}
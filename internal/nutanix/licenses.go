@@ -0,0 +1,194 @@
+package nutanix
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	KEY_LICENSE_INFO        = "info"
+	METRIC_LICENSE_EXPIRY   = "expiry_timestamp_seconds"
+	METRIC_LICENSE_FEATURE  = "feature_enabled"
+	METRIC_LICENSE_CAPACITY = "capacity"
+	LICENSE_DATE_LAYOUT     = "2006-01-02"
+	BYTES_PER_TIB           = 1024 * 1024 * 1024 * 1024
+)
+
+// licenseCapacityAllowances maps numeric license allowances to the resource
+// label of the licensed capacity
+var licenseCapacityAllowances = map[string]string{
+	"NUM_LICENSED_CORES": "cores",
+	"LICENSED_TIB":       "tib",
+}
+
+// LicensesExporter
+type LicensesExporter struct {
+	*nutanixExporter
+	cluster map[string]interface{}
+	hosts   []interface{}
+}
+
+// parseLicenseExpiry returns the expiry of the license as unix timestamp.
+// Prism reports it either as epoch milliseconds or as date.
+func parseLicenseExpiry(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		if v <= 0 {
+			return 0, false
+		}
+		return v / 1000, true
+	case string:
+		if t, err := time.Parse(LICENSE_DATE_LAYOUT, v); err == nil {
+			return float64(t.Unix()), true
+		}
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return ms / 1000, true
+		}
+	}
+	return 0, false
+}
+
+// Describe - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *LicensesExporter) Describe(ch chan<- *prometheus.Desc) {
+	resp, err := e.api.makeV1Request("GET", "/license", nil)
+	if err != nil {
+		e.result = nil
+		e.api.Logger().Error("License discovery failed")
+		return
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&e.result); err != nil {
+		e.result = nil
+		e.api.Logger().Error("Failed to decode license response")
+		return
+	}
+
+	// Cluster and hosts are needed for the used capacity
+	resp, err = e.api.makeV2Request("GET", "/cluster/", nil)
+	if err != nil {
+		e.api.Logger().Error("Cluster discovery for licenses failed")
+	} else {
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(&e.cluster); err != nil {
+			e.cluster = nil
+			e.api.Logger().Error("Failed to decode cluster response")
+		}
+	}
+	e.hosts, err = e.api.fetchAllPages("/hosts", nil)
+	if err != nil {
+		e.hosts = nil
+		e.api.Logger().Error("Host discovery for licenses failed")
+	}
+
+	e.metrics[KEY_LICENSE_INFO] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      KEY_LICENSE_INFO,
+		Help:      "License tier of the cluster"}, []string{"cluster_uuid", "category", "sub_category"})
+	e.metrics[KEY_LICENSE_INFO].Describe(ch)
+
+	e.metrics[METRIC_LICENSE_EXPIRY] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_LICENSE_EXPIRY,
+		Help:      "License expiry as unix timestamp"}, []string{"cluster_uuid"})
+	e.metrics[METRIC_LICENSE_EXPIRY].Describe(ch)
+
+	e.metrics[METRIC_LICENSE_FEATURE] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_LICENSE_FEATURE,
+		Help:      "License state of a feature"}, []string{"cluster_uuid", "feature"})
+	e.metrics[METRIC_LICENSE_FEATURE].Describe(ch)
+
+	e.metrics[METRIC_LICENSE_CAPACITY] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_LICENSE_CAPACITY,
+		Help:      "Licensed and used capacity"}, []string{"cluster_uuid", "resource", "state"})
+	e.metrics[METRIC_LICENSE_CAPACITY].Describe(ch)
+}
+
+// Collect - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *LicensesExporter) Collect(ch chan<- prometheus.Metric) {
+	if e.result == nil {
+		return
+	}
+	clusterUUID, _ := e.result["clusterUuid"].(string)
+	if len(clusterUUID) == 0 && e.cluster != nil {
+		clusterUUID, _ = e.cluster["uuid"].(string)
+	}
+
+	category, _ := e.result["category"].(string)
+	subCategory, _ := e.result["subCategory"].(string)
+	g := e.metrics[KEY_LICENSE_INFO].WithLabelValues(clusterUUID, category, subCategory)
+	g.Set(1)
+	g.Collect(ch)
+
+	for _, key := range []string{"expiryDate", "licenseExpiryDate"} {
+		if expiry, ok := parseLicenseExpiry(e.result[key]); ok {
+			g := e.metrics[METRIC_LICENSE_EXPIRY].WithLabelValues(clusterUUID)
+			g.Set(expiry)
+			g.Collect(ch)
+			break
+		}
+	}
+
+	allowances, _ := e.result["allowances"].([]interface{})
+	for _, allowanceRaw := range allowances {
+		allowance, ok := allowanceRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		feature, _ := allowance["featureName"].(string)
+		value := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", allowance["value"])))
+
+		if resource, ok := licenseCapacityAllowances[strings.ToUpper(feature)]; ok {
+			g := e.metrics[METRIC_LICENSE_CAPACITY].WithLabelValues(clusterUUID, resource, "licensed")
+			g.Set(e.valueToFloat64(value))
+			g.Collect(ch)
+			continue
+		}
+
+		var enabled float64 = 0
+		if value == "true" {
+			enabled = 1
+		}
+		g := e.metrics[METRIC_LICENSE_FEATURE].WithLabelValues(clusterUUID, e.normalizeKey(feature))
+		g.Set(enabled)
+		g.Collect(ch)
+	}
+
+	if e.hosts != nil {
+		var cores float64 = 0
+		for _, hostRaw := range e.hosts {
+			host := hostRaw.(map[string]interface{})
+			cores += e.valueToFloat64(host["num_cpu_cores"])
+		}
+		g := e.metrics[METRIC_LICENSE_CAPACITY].WithLabelValues(clusterUUID, "cores", "used")
+		g.Set(cores)
+		g.Collect(ch)
+	}
+	if usageStats, ok := e.cluster["usage_stats"].(map[string]interface{}); ok {
+		if usage, ok := usageStats["storage.usage_bytes"]; ok {
+			g := e.metrics[METRIC_LICENSE_CAPACITY].WithLabelValues(clusterUUID, "tib", "used")
+			g.Set(e.valueToFloat64(usage) / BYTES_PER_TIB)
+			g.Collect(ch)
+		}
+	}
+	e.api.Logger().Debug("License data collected for cluster UUID : ", clusterUUID)
+}
+
+// NewLicensesCollector
+func NewLicensesCollector(_api *Nutanix) *LicensesExporter {
+	return &LicensesExporter{
+		nutanixExporter: &nutanixExporter{
+			api:       *_api,
+			metrics:   make(map[string]*prometheus.GaugeVec),
+			namespace: "nutanix_license",
+		},
+	}
+}
@@ -0,0 +1,25 @@
+package nutanix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLicenseExpiry(t *testing.T) {
+	// epoch milliseconds
+	expiry, ok := parseLicenseExpiry(float64(1767225600000))
+	assert.True(t, ok)
+	assert.Equal(t, float64(1767225600), expiry)
+
+	// date
+	expiry, ok = parseLicenseExpiry("2026-01-01")
+	assert.True(t, ok)
+	assert.Equal(t, float64(1767225600), expiry)
+
+	// not available
+	_, ok = parseLicenseExpiry(nil)
+	assert.False(t, ok)
+	_, ok = parseLicenseExpiry("never")
+	assert.False(t, ok)
+}
@@ -0,0 +1,228 @@
+package nutanix
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	METRIC_NAMING_V1 = "v1"
+	METRIC_NAMING_V2 = "v2"
+)
+
+// metricMeta describes a stat or field published by the collectors
+type metricMeta struct {
+	help    string
+	name    string  // name under the v2 naming scheme, the normalized key if empty
+	scale   float64 // factor to the base unit under the v2 naming scheme, 1 if zero
+	counter bool    // monotonic value, exported as counter
+}
+
+// metricCatalog holds the metadata of the known stats and fields, keyed by
+// the Prism attribute name
+var metricCatalog = map[string]metricMeta{
+	// Property records
+	"properties":        {help: "Properties of the entity as labels, always 1"},
+	KEY_INFO:            {help: "Identity of the entity as labels, always 1"},
+	KEY_IP_ADDRESS_INFO: {help: "IP address of the entity, always 1"},
+
+	// Storage
+	"storage.capacity_bytes":                    {help: "Storage capacity", name: "storage_capacity_bytes"},
+	"storage.usage_bytes":                       {help: "Used storage", name: "storage_usage_bytes"},
+	"storage.logical_usage_bytes":               {help: "Logical storage usage before data reduction", name: "storage_logical_usage_bytes"},
+	"storage.container_reserved_capacity_bytes": {help: "Storage capacity reserved for the container", name: "storage_container_reserved_capacity_bytes"},
+	"storage.free_bytes":                        {help: "Free storage", name: "storage_free_bytes"},
+
+	// Controller I/O
+	"controller_total_io_size_kbytes":       {help: "Size of the I/O served by the controller", name: "controller_io_size_bytes", scale: 1024},
+	"controller_total_read_io_size_kbytes":  {help: "Size of the read I/O served by the controller", name: "controller_read_io_size_bytes", scale: 1024},
+	METRIC_TOTAL_WRITE_IO_SIZE:              {help: "Size of the write I/O served by the controller", name: "controller_write_io_size_bytes", scale: 1024},
+	"controller_num_io":                     {help: "I/O operations served by the controller", name: "controller_io"},
+	"controller_num_read_io":                {help: "Read I/O operations served by the controller", name: "controller_read_io"},
+	"controller_num_write_io":               {help: "Write I/O operations served by the controller", name: "controller_write_io"},
+	"controller_num_iops":                   {help: "I/O operations per second served by the controller", name: "controller_iops"},
+	"controller_num_read_iops":              {help: "Read I/O operations per second served by the controller", name: "controller_read_iops"},
+	"controller_num_write_iops":             {help: "Write I/O operations per second served by the controller", name: "controller_write_iops"},
+	"controller_io_bandwidth_kBps":          {help: "I/O bandwidth of the controller", name: "controller_io_bandwidth_bytes_per_second", scale: 1024},
+	"controller_read_io_bandwidth_kBps":     {help: "Read I/O bandwidth of the controller", name: "controller_read_io_bandwidth_bytes_per_second", scale: 1024},
+	"controller_write_io_bandwidth_kBps":    {help: "Write I/O bandwidth of the controller", name: "controller_write_io_bandwidth_bytes_per_second", scale: 1024},
+	"controller_avg_io_latency_usecs":       {help: "Average I/O latency of the controller", name: "controller_io_latency_seconds", scale: 1e-6},
+	"controller_avg_read_io_latency_usecs":  {help: "Average read I/O latency of the controller", name: "controller_read_io_latency_seconds", scale: 1e-6},
+	"controller_avg_write_io_latency_usecs": {help: "Average write I/O latency of the controller", name: "controller_write_io_latency_seconds", scale: 1e-6},
+	"controller_user_bytes":                 {help: "Storage used by user data", name: "controller_user_bytes"},
+	"controller_timespan_usecs":             {help: "Sampling interval of the controller stats", name: "controller_timespan_seconds", scale: 1e-6},
+
+	// Hypervisor
+	"hypervisor_cpu_usage_ppm":         {help: "CPU usage reported by the hypervisor", name: "hypervisor_cpu_usage_ratio", scale: 1e-6},
+	"hypervisor_memory_usage_ppm":      {help: "Memory usage reported by the hypervisor", name: "hypervisor_memory_usage_ratio", scale: 1e-6},
+	"hypervisor.cpu_ready_time_ppm":    {help: "Time the vCPUs were ready but not scheduled", name: "hypervisor_cpu_ready_time_ratio", scale: 1e-6},
+	"hypervisor_num_received_bytes":    {help: "Bytes received reported by the hypervisor", name: "hypervisor_received_bytes", counter: true},
+	"hypervisor_num_transmitted_bytes": {help: "Bytes transmitted reported by the hypervisor", name: "hypervisor_transmitted_bytes", counter: true},
+	"cpu_capacity_in_hz":               {help: "CPU capacity", name: "cpu_capacity_hertz"},
+
+	// Calculated memory
+	METRIC_MEM_USAGE_BYTES:      {help: "Memory in use"},
+	METRIC_MEM_FREE_BYTES:       {help: "Free memory"},
+	METRIC_HA_RESERVED:          {help: "Memory reserved for HA failover"},
+	METRIC_MEM_SWAPPED_IN_RATE:  {help: "Rate of memory swapped in by the guest", name: "memory_swapped_in_bytes_per_second"},
+	METRIC_MEM_SWAPPED_OUT_RATE: {help: "Rate of memory swapped out by the guest", name: "memory_swapped_out_bytes_per_second"},
+
+	// Network
+	"network.received_bytes":           {help: "Bytes received by the NIC", name: "network_received_bytes", counter: true},
+	"network.transmitted_bytes":        {help: "Bytes transmitted by the NIC", name: "network_transmitted_bytes", counter: true},
+	"network.received_pkts":            {help: "Packets received by the NIC", name: "network_received_packets", counter: true},
+	"network.transmitted_pkts":         {help: "Packets transmitted by the NIC", name: "network_transmitted_packets", counter: true},
+	"network.error_received_pkts":      {help: "Receive errors of the NIC", name: "network_received_error_packets", counter: true},
+	"network.error_transmitted_pkts":   {help: "Transmit errors of the NIC", name: "network_transmitted_error_packets", counter: true},
+	"network.dropped_received_pkts":    {help: "Received packets dropped by the NIC", name: "network_received_dropped_packets", counter: true},
+	"network.dropped_transmitted_pkts": {help: "Transmitted packets dropped by the NIC", name: "network_transmitted_dropped_packets", counter: true},
+
+	// NIC fields
+	"mtuInBytes":   {help: "MTU of the NIC", name: "mtu_bytes"},
+	"mtu_in_bytes": {help: "MTU of the NIC", name: "mtu_bytes"},
+
+	// Storage container fields
+	"replication_factor":  {help: "Replication factor of the storage container"},
+	"compression_enabled": {help: "Compression is enabled for the storage container"},
+	"max_capacity":        {help: "Maximum capacity of the storage container", name: "max_capacity_bytes"},
+
+	// Cluster fields
+	"num_nodes": {help: "Nodes of the cluster"},
+
+	// Host fields
+	"num_vms":                  {help: "VMs running on the host"},
+	"num_cpu_cores":            {help: "CPU cores of the host"},
+	"num_cpu_sockets":          {help: "CPU sockets of the host"},
+	"num_cpu_threads":          {help: "CPU threads of the host"},
+	"cpu_frequency_in_hz":      {help: "CPU frequency of the host", name: "cpu_frequency_hertz"},
+	"memory_capacity_in_bytes": {help: "Memory capacity of the host", name: "memory_capacity_bytes"},
+	"boot_time_in_usecs":       {help: "Boot time of the host", name: "boot_time_seconds", scale: 1e-6},
+
+	KEY_HOST_HYPERVISOR_INFO: {help: "Hypervisor running on the host, always 1"},
+	KEY_VM_HOST_INFO:         {help: "Host the VM is running on, always 1"},
+
+	// Host availability
+	METRIC_HOST_MAINTENANCE:     {help: "Host is in maintenance mode"},
+	METRIC_HOST_DEGRADED:        {help: "Host is degraded"},
+	METRIC_HOST_STATE:           {help: "State of the host"},
+	METRIC_HOST_HYPERVISOR:      {help: "State of the hypervisor"},
+	METRIC_HOST_MAINT_TARGET:    {help: "Target of the maintenance mode of the host"},
+	METRIC_HOST_METADATA_STATUS: {help: "Status of the metadata store on the host"},
+	METRIC_HOST_CVM_STATE:       {help: "Power state of the controller VM of the host"},
+
+	// VM fields
+	"memoryCapacityInBytes":         {help: "Memory capacity of the VM", name: "memory_capacity_bytes"},
+	"numVCpus":                      {help: "vCPUs of the VM", name: "num_vcpus"},
+	"powerState":                    {help: "VM is powered on", name: "powered_on"},
+	"cpuReservedInHz":               {help: "CPU reserved for the VM", name: "cpu_reserved_hertz"},
+	"memoryReservedCapacityInBytes": {help: "Memory reserved for the VM", name: "memory_reserved_bytes"},
+	"diskCapacityInBytes":           {help: "Disk capacity of the VM", name: "disk_capacity_bytes"},
+
+	// VM state
+	METRIC_VM_POWER_STATE:      {help: "Power state of the VM"},
+	METRIC_VM_NGT_INSTALLED:    {help: "Nutanix Guest Tools are installed in the VM"},
+	METRIC_VM_NGT_ENABLED:      {help: "Nutanix Guest Tools are enabled for the VM"},
+	METRIC_VM_NGT_MOUNTED:      {help: "Nutanix Guest Tools ISO is mounted in the VM"},
+	METRIC_VM_NGT_COMMUNICATES: {help: "Nutanix Guest Tools communicate with the CVM"},
+	METRIC_VM_HA_PRIORITY:      {help: "HA restart priority of the VM"},
+	METRIC_VM_AGENT:            {help: "VM is an agent VM"},
+	METRIC_VM_AFFINITY:         {help: "VM is pinned to hosts by an affinity rule"},
+
+	// Virtual disk fields
+	"disk_capacity_in_bytes": {help: "Capacity of the virtual disk", name: "capacity_bytes"},
+
+	// Image fields
+	METRIC_IMAGE_SIZE_BYTES:   {help: "Size of the image"},
+	METRIC_IMAGE_CREATED_TIME: {help: "Creation time of the image", name: "created_time_seconds", scale: 1e-6},
+
+	// Network fields
+	METRIC_NET_POOL_SIZE:      {help: "Addresses in the IP pools of the managed network"},
+	METRIC_NET_ASSIGNED_ADDRS: {help: "Addresses assigned by the IPAM of the managed network"},
+	METRIC_NET_FREE_ADDRS:     {help: "Free addresses in the IP pools of the managed network"},
+	METRIC_NET_VM_NICS:        {help: "VM NICs attached to the network"},
+
+	// Volume group fields
+	METRIC_VG_NUM_DISKS:      {help: "Disks of the volume group"},
+	METRIC_VG_CAPACITY_BYTES: {help: "Capacity of the volume group"},
+
+	// Snapshot fields
+	"created_time": {help: "Creation time of the snapshot", name: "created_time_seconds", scale: 1e-6},
+}
+
+// SetMetricNaming selects the naming scheme of the metrics, v1 exports the
+// Prism names and units as they are
+func (e *nutanixExporter) SetMetricNaming(naming string) {
+	e.naming = naming
+}
+
+// metricName returns the metric name of a stat or field
+func (e *nutanixExporter) metricName(key string) string {
+	if e.naming == METRIC_NAMING_V2 {
+		if meta, ok := metricCatalog[key]; ok {
+			name := meta.name
+			if len(name) == 0 {
+				name = e.normalizeKey(key)
+			}
+			if meta.counter {
+				name += "_total"
+			}
+			return name
+		}
+	}
+	return e.normalizeKey(key)
+}
+
+// metricHelp returns the HELP text of a stat or field
+func (e *nutanixExporter) metricHelp(key string) string {
+	if meta, ok := metricCatalog[key]; ok {
+		return meta.help
+	}
+	return "Prism attribute " + key
+}
+
+// metricValue converts the value of a stat or field to its base unit
+func (e *nutanixExporter) metricValue(key string, value float64) float64 {
+	if e.naming == METRIC_NAMING_V2 {
+		if meta, ok := metricCatalog[key]; ok && meta.scale != 0 {
+			return value * meta.scale
+		}
+	}
+	return value
+}
+
+// isCounter returns true if the stat is exported as counter, v1 exports all
+// stats as gauges
+func (e *nutanixExporter) isCounter(key string) bool {
+	return e.naming == METRIC_NAMING_V2 && metricCatalog[key].counter
+}
+
+// describeStat registers the metric of a stat or field
+func (e *nutanixExporter) describeStat(ch chan<- *prometheus.Desc, key string, labels []string) {
+	name := e.metricName(key)
+	if e.isCounter(key) {
+		if e.counters == nil {
+			e.counters = make(map[string]*prometheus.Desc)
+		}
+		e.counters[name] = prometheus.NewDesc(prometheus.BuildFQName(e.namespace, "", name), e.metricHelp(key), labels, nil)
+		ch <- e.counters[name]
+		return
+	}
+	e.metrics[name] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      name,
+		Help:      e.metricHelp(key)}, labels)
+	e.metrics[name].Describe(ch)
+}
+
+// collectStat publishes the value of a stat or field
+func (e *nutanixExporter) collectStat(ch chan<- prometheus.Metric, key string, value float64, labelValues ...string) {
+	name := e.metricName(key)
+	if desc, ok := e.counters[name]; ok {
+		value = e.metricValue(key, value)
+		e.checkCounterReset(prometheus.BuildFQName(e.namespace, "", name), labelValues, value)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, labelValues...)
+		return
+	}
+	g := e.metrics[name].WithLabelValues(labelValues...)
+	g.Set(e.metricValue(key, value))
+	g.Collect(ch)
+}
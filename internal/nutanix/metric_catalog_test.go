@@ -0,0 +1,29 @@
+package nutanix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricNaming(t *testing.T) {
+	e := &nutanixExporter{}
+
+	// v1 keeps the Prism names and units
+	assert.Equal(t, "hypervisor_cpu_usage_ppm", e.metricName("hypervisor_cpu_usage_ppm"))
+	assert.Equal(t, float64(250000), e.metricValue("hypervisor_cpu_usage_ppm", 250000))
+	assert.Equal(t, "CPU usage reported by the hypervisor", e.metricHelp("hypervisor_cpu_usage_ppm"))
+
+	e.SetMetricNaming(METRIC_NAMING_V2)
+	assert.Equal(t, "hypervisor_cpu_usage_ratio", e.metricName("hypervisor_cpu_usage_ppm"))
+	assert.Equal(t, 0.25, e.metricValue("hypervisor_cpu_usage_ppm", 250000))
+	assert.Equal(t, "controller_read_io_latency_seconds", e.metricName("controller_avg_read_io_latency_usecs"))
+	assert.Equal(t, 0.002, e.metricValue("controller_avg_read_io_latency_usecs", 2000))
+	assert.Equal(t, "controller_io_size_bytes", e.metricName("controller_total_io_size_kbytes"))
+	assert.Equal(t, float64(2048), e.metricValue("controller_total_io_size_kbytes", 2))
+
+	// Unknown stats keep their normalized name and value
+	assert.Equal(t, "storage_tier_ssd_usage_bytes", e.metricName("storage_tier.ssd.usage_bytes"))
+	assert.Equal(t, float64(42), e.metricValue("storage_tier.ssd.usage_bytes", 42))
+	assert.Equal(t, "Prism attribute storage_tier.ssd.usage_bytes", e.metricHelp("storage_tier.ssd.usage_bytes"))
+}
@@ -0,0 +1,263 @@
+package nutanix
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	KEY_NETWORK_PROPERTIES     = "properties"
+	METRIC_NET_POOL_SIZE       = "ip_pool_size"
+	METRIC_NET_ASSIGNED_ADDRS  = "ip_assigned_addresses"
+	METRIC_NET_FREE_ADDRS      = "ip_free_addresses"
+	METRIC_NET_VM_NICS         = "vm_nics"
+	NETWORK_MANAGED_PROPERTY   = "managed"
+	NETWORK_ADDRESSES_ENDPOINT = "/networks/%s/addresses"
+)
+
+// NetworksExporter
+type NetworksExporter struct {
+	*nutanixExporter
+	vms           *VmsExporter
+	assignedAddrs map[string]float64 // network uuid -> assigned addresses
+	nicsByNetwork map[string]float64 // network uuid -> vm nics
+	mu            sync.Mutex
+}
+
+// isManaged returns true if IPAM is enabled for the network
+func (e *NetworksExporter) isManaged(ent map[string]interface{}) bool {
+	ipConfig, ok := ent["ip_config"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	addr, ok := ipConfig["network_address"].(string)
+	return ok && len(addr) > 0
+}
+
+// ipPoolSize returns the number of addresses in the IPAM pools of the network.
+// Pool ranges are reported as "<first address> <last address>".
+func ipPoolSize(ent map[string]interface{}, logger *log.Logger) float64 {
+	ipConfig, ok := ent["ip_config"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	pools, ok := ipConfig["pool"].([]interface{})
+	if !ok {
+		return 0
+	}
+
+	var size float64 = 0
+	for _, poolRaw := range pools {
+		pool, ok := poolRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rng, ok := pool["range"].(string)
+		if !ok {
+			continue
+		}
+		bounds := strings.Fields(rng)
+		if len(bounds) != 2 {
+			logger.Warnf("Invalid IP pool range %q", rng)
+			continue
+		}
+		first, last := net.ParseIP(bounds[0]).To4(), net.ParseIP(bounds[1]).To4()
+		if first == nil || last == nil {
+			logger.Warnf("Invalid IP pool range %q", rng)
+			continue
+		}
+		start, end := binary.BigEndian.Uint32(first), binary.BigEndian.Uint32(last)
+		if end < start {
+			continue
+		}
+		size += float64(end-start) + 1
+	}
+	return size
+}
+
+// fetchAssignedAddresses loads the number of addresses handed out by IPAM for
+// each managed network
+func (e *NetworksExporter) fetchAssignedAddresses(uuids []string) {
+	e.assignedAddrs = make(map[string]float64)
+
+	var wg sync.WaitGroup
+	// Create a buffered channel to limit concurrent requests
+	semaphore := make(chan struct{}, e.api.maxParallelRequests)
+	for _, uuid := range uuids {
+		wg.Add(1)
+		go func(uuid string) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire a token
+			defer func() { <-semaphore }() // Release the token
+			entities, err := e.api.fetchAllPages(fmt.Sprintf(NETWORK_ADDRESSES_ENDPOINT, uuid), nil)
+			if err != nil {
+				e.api.Logger().Errorf("Network address discovery failed for network %s: %v", uuid, err)
+				return
+			}
+			e.mu.Lock()
+			e.assignedAddrs[uuid] = float64(len(entities))
+			e.mu.Unlock()
+		}(uuid)
+	}
+	wg.Wait()
+}
+
+// countVMNics counts VM NICs per network from the NICs discovered by the
+// vmnics collector of the VMs collector. The counts are left nil when the
+// vmnics collector does not run.
+func (e *NetworksExporter) countVMNics() {
+	e.nicsByNetwork = nil
+	if e.vms == nil || !e.vms.collectvmnics || e.vms.result == nil {
+		e.api.Logger().Debugf("No VM NICs discovered for networks")
+		return
+	}
+
+	e.nicsByNetwork = make(map[string]float64)
+	for _, nics := range e.vms.networkExporters {
+		if nics.result == nil {
+			continue
+		}
+		entities, _ := nics.result["entities"].([]interface{})
+		for _, nicRaw := range entities {
+			nic, ok := nicRaw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if networkUUID, ok := nic["networkUuid"].(string); ok {
+				e.nicsByNetwork[networkUUID]++
+			}
+		}
+	}
+}
+
+// Describe - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *NetworksExporter) Describe(ch chan<- *prometheus.Desc) {
+	entities, err := e.api.fetchAllPages("/networks", nil)
+	if err != nil {
+		e.result = nil
+		e.api.Logger().Error("Network discovery failed")
+		return
+	}
+
+	e.result = map[string]interface{}{"entities": entities}
+
+	key := KEY_NETWORK_PROPERTIES
+	e.describeStat(ch, key, e.properties)
+
+	e.countVMNics()
+	for _, key := range e.fields {
+		if key == METRIC_NET_VM_NICS && e.nicsByNetwork == nil {
+			continue
+		}
+		e.describeStat(ch, key, []string{"uuid"})
+	}
+
+	if len(entities) == 0 {
+		return
+	}
+
+	managed := []string{}
+	for _, entRaw := range entities {
+		ent := entRaw.(map[string]interface{})
+		if uuid, ok := ent["uuid"].(string); ok && e.isManaged(ent) {
+			managed = append(managed, uuid)
+		}
+	}
+	e.fetchAssignedAddresses(managed)
+}
+
+// Collect - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *NetworksExporter) Collect(ch chan<- prometheus.Metric) {
+	if e.result == nil {
+		return
+	}
+	var entities []interface{} = nil
+	if obj, ok := e.result["entities"]; ok {
+		entities = obj.([]interface{})
+	}
+	if entities == nil {
+		return
+	}
+
+	for _, entity := range entities {
+		ent := entity.(map[string]interface{})
+		uuid, _ := ent["uuid"].(string)
+		managed := e.isManaged(ent)
+
+		key := KEY_NETWORK_PROPERTIES
+		var property_values []string
+		for _, property := range e.properties {
+			var val string = ""
+			switch property {
+			case NETWORK_MANAGED_PROPERTY:
+				val = fmt.Sprintf("%v", managed)
+			default:
+				obj := ent[property]
+				if obj != nil {
+					val = fmt.Sprintf("%v", obj)
+				}
+			}
+			property_values = append(property_values, val)
+		}
+		g := e.metrics[key].WithLabelValues(property_values...)
+		g.Set(1)
+		g.Collect(ch)
+
+		poolSize := ipPoolSize(ent, e.api.Logger())
+		assigned := e.assignedAddrs[uuid]
+
+		for _, key := range e.fields {
+			var val float64
+			switch key {
+			case METRIC_NET_POOL_SIZE, METRIC_NET_ASSIGNED_ADDRS, METRIC_NET_FREE_ADDRS:
+				// IPAM metrics only exist for managed networks
+				if !managed {
+					continue
+				}
+				switch key {
+				case METRIC_NET_POOL_SIZE:
+					val = poolSize
+				case METRIC_NET_ASSIGNED_ADDRS:
+					val = assigned
+				default:
+					val = poolSize - assigned
+					if val < 0 {
+						val = 0
+					}
+				}
+			case METRIC_NET_VM_NICS:
+				if e.nicsByNetwork == nil {
+					continue
+				}
+				val = e.nicsByNetwork[uuid]
+			default:
+				val = e.valueToFloat64(ent[key])
+			}
+			e.collectStat(ch, key, val, uuid)
+		}
+		e.api.Logger().Debugf("Network data collected for network: %s (UUID: %s)", ent["name"], uuid)
+	}
+}
+
+// NewNetworksCollector - vms is optional and used to count the VM NICs per
+// network from its vmnics collector
+func NewNetworksCollector(_api *Nutanix, vms *VmsExporter) *NetworksExporter {
+	return &NetworksExporter{
+		vms: vms,
+		nutanixExporter: &nutanixExporter{
+			api:        *_api,
+			metrics:    make(map[string]*prometheus.GaugeVec),
+			namespace:  "nutanix_networks",
+			fields:     []string{METRIC_NET_POOL_SIZE, METRIC_NET_ASSIGNED_ADDRS, METRIC_NET_FREE_ADDRS, METRIC_NET_VM_NICS},
+			properties: []string{"uuid", "name", "vlan_id", NETWORK_MANAGED_PROPERTY},
+		},
+	}
+}
@@ -0,0 +1,87 @@
+package nutanix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPPoolSize(t *testing.T) {
+	ent := map[string]interface{}{
+		"ip_config": map[string]interface{}{
+			"network_address": "10.0.0.0",
+			"pool": []interface{}{
+				map[string]interface{}{"range": "10.0.0.10 10.0.0.19"},
+				map[string]interface{}{"range": "10.0.1.0 10.0.1.255"},
+			},
+		},
+	}
+	assert.Equal(t, float64(10+256), ipPoolSize(ent, log.StandardLogger()))
+
+	// Invalid and reversed ranges are ignored
+	ent["ip_config"].(map[string]interface{})["pool"] = []interface{}{
+		map[string]interface{}{"range": "10.0.0.10"},
+		map[string]interface{}{"range": "10.0.0.20 10.0.0.10"},
+	}
+	assert.Equal(t, float64(0), ipPoolSize(ent, log.StandardLogger()))
+
+	// Unmanaged networks have no pool
+	assert.Equal(t, float64(0), ipPoolSize(map[string]interface{}{}, log.StandardLogger()))
+}
+
+func TestNetworksVMNics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PrismGateway/services/rest/v2.0/networks/":
+			w.Write([]byte(`{"metadata": {"grand_total_entities": 2, "end_index": 2}, "entities": [
+				{"uuid": "n1", "name": "prod", "vlan_id": 10}, {"uuid": "n2", "name": "backup", "vlan_id": 20}]}`))
+		case "/PrismGateway/services/rest/v1/vms/":
+			w.Write([]byte(`{"metadata": {"grandTotalEntities": 2, "endIndex": 2}, "entities": [
+				{"uuid": "vm1", "vmName": "web", "powerState": "on"}, {"uuid": "vm2", "vmName": "db", "powerState": "on"}]}`))
+		case "/PrismGateway/services/rest/v1/vms/vm1/virtual_nics/":
+			w.Write([]byte(`[{"uuid": "nic1", "vmUuid": "vm1", "networkUuid": "n1"}, {"uuid": "nic2", "vmUuid": "vm1", "networkUuid": "n2"}]`))
+		case "/PrismGateway/services/rest/v1/vms/vm2/virtual_nics/":
+			w.Write([]byte(`[{"uuid": "nic3", "vmUuid": "vm2", "networkUuid": "n1"}]`))
+		case "/PrismGateway/services/rest/v2.0/vms/":
+			t.Errorf("unexpected VM list request for networks")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	api := NewNutanix(server.URL, "user", "pass", 2)
+
+	// The NICs of the vmnics collector are counted
+	vms := NewVmsCollector(api, true)
+	testutil.CollectAndCount(vms)
+	collector := NewNetworksCollector(api, vms)
+	expected := `
+# HELP nutanix_networks_vm_nics VM NICs attached to the network
+# TYPE nutanix_networks_vm_nics gauge
+nutanix_networks_vm_nics{uuid="n1"} 2
+nutanix_networks_vm_nics{uuid="n2"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "nutanix_networks_vm_nics"))
+
+	// Without the vmnics collector, the VM NICs are not published
+	vms = NewVmsCollector(api, false)
+	testutil.CollectAndCount(vms)
+	assert.Equal(t, 0, testutil.CollectAndCount(NewNetworksCollector(api, vms), "nutanix_networks_vm_nics"))
+	assert.Equal(t, 0, testutil.CollectAndCount(NewNetworksCollector(api, nil), "nutanix_networks_vm_nics"))
+
+	// Other fields are read from the entity
+	collector = NewNetworksCollector(api, nil)
+	collector.Configure(&CollectorConfig{Fields: []string{"vlan_id"}})
+	expected = `
+# HELP nutanix_networks_vlan_id Prism attribute vlan_id
+# TYPE nutanix_networks_vlan_id gauge
+nutanix_networks_vlan_id{uuid="n1"} 10
+nutanix_networks_vlan_id{uuid="n2"} 20
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "nutanix_networks_vlan_id"))
+}
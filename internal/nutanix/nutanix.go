@@ -11,12 +11,15 @@ package nutanix
 
 import (
 	//	"os"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -25,6 +28,8 @@ import (
 const (
 	PRISM_API_PATH_VERSION_V1     = "v1/"
 	PRISM_API_PATH_VERSION_V2     = "v2.0/"
+	PRISM_API_PATH_VERSION_V3     = "nutanix/v3/"
+	PRISM_API_PATH_LCM_V4         = "lcm/v4.0/resources/"
 	HTTP_TIMEOUT                  = 10 * time.Second
 	MAX_PARALLEL_REQUESTS_DEFAULT = 10
 )
@@ -32,13 +37,23 @@ const (
 type RequestParams struct {
 	body   string
 	params url.Values
+	// optional APIs missing on the cluster return errNotAvailable and are
+	// not counted as failed requests
+	optional bool
 }
 
+// errNotAvailable is returned for optional APIs the cluster does not provide
+var errNotAvailable = errors.New("API not available")
+
 type Nutanix struct {
 	url                 string
 	username            string
 	password            string
 	maxParallelRequests int
+	failures            *atomic.Uint64 // failed requests of the client, see Fork
+	recording           *Recording
+	ctx                 context.Context // cancels the requests of the client
+	logger              *log.Logger     // logs the requests and collectors of the client
 }
 
 func (g *Nutanix) makeV1Request(reqType string, action string, params url.Values) (*http.Response, error) {
@@ -49,12 +64,35 @@ func (g *Nutanix) makeV2Request(reqType string, action string, params url.Values
 	return g.makeRequestWithParams(PRISM_API_PATH_VERSION_V2, reqType, action, RequestParams{params: params})
 }
 
+func (g *Nutanix) makeV2PostRequest(action string, body string) (*http.Response, error) {
+	return g.makeRequestWithParams(PRISM_API_PATH_VERSION_V2, "POST", action, RequestParams{body: body})
+}
+
+// makeV4Request calls the v4 namespaced APIs, e.g. apiPath = "lcm/v4.0/resources/"
+func (g *Nutanix) makeV4RequestWithParams(reqType, apiPath, action string, p RequestParams) (*http.Response, error) {
+	_url := strings.Trim(g.url, "/")
+	_url += "/api/" + apiPath
+	_url += strings.Trim(action, "/")
+	return g.makeRequest(reqType, _url, p)
+}
+
+// makeV3PostRequest calls the Prism Central v3 APIs, e.g. action = "/vms/list"
+func (g *Nutanix) makeV3PostRequest(action string, body string) (*http.Response, error) {
+	_url := strings.Trim(g.url, "/")
+	_url += "/api/" + PRISM_API_PATH_VERSION_V3
+	_url += strings.Trim(action, "/")
+	return g.makeRequest("POST", _url, RequestParams{body: body})
+}
+
 func (g *Nutanix) makeRequestWithParams(versionPath, reqType, action string, p RequestParams) (*http.Response, error) {
 	_url := strings.Trim(g.url, "/")
 	_url += "/PrismGateway/services/rest/" + versionPath
 	_url += strings.Trim(action, "/") + "/"
+	return g.makeRequest(reqType, _url, p)
+}
 
-	log.Debugf("URL: %s", _url)
+func (g *Nutanix) makeRequest(reqType, _url string, p RequestParams) (*http.Response, error) {
+	g.Logger().Debugf("URL: %s", _url)
 
 	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
 	var netClient = http.Client{
@@ -68,19 +106,33 @@ func (g *Nutanix) makeRequestWithParams(versionPath, reqType, action string, p R
 		_url += "?" + p.params.Encode()
 	}
 
-	req, err := http.NewRequest(reqType, _url, strings.NewReader(body))
+	req, err := http.NewRequestWithContext(g.Context(), reqType, _url, strings.NewReader(body))
 	if err != nil {
-		log.Errorf("failed to create request; error=%v\n", err)
+		g.Logger().Errorf("failed to create request; error=%v\n", err)
 		return nil, err
 	}
 	//req.Header.Set("Content-Type", "text/JSON")
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	req.SetBasicAuth(g.username, g.password)
 
 	start := time.Now()
-	resp, err := netClient.Do(req)
+	var resp *http.Response
+	switch {
+	case g.recording != nil && g.recording.Mode == RECORDING_MODE_REPLAY:
+		resp, err = g.recording.replay(req, body)
+	case g.recording != nil && g.recording.Mode == RECORDING_MODE_RECORD:
+		resp, err = netClient.Do(req)
+		if err == nil {
+			resp, err = g.recording.record(req, body, resp)
+		}
+	default:
+		resp, err = netClient.Do(req)
+	}
 	if err != nil {
-		log.Errorf("failed to execute request; error=%v\n", err)
+		g.Logger().Errorf("failed to execute request; error=%v\n", err)
 		// heuristics for health
 		if strings.Contains(strings.ToLower(err.Error()), "timeout") {
 			IncConnTimeout(g.url)
@@ -90,12 +142,20 @@ func (g *Nutanix) makeRequestWithParams(versionPath, reqType, action string, p R
 			IncException(g.url)
 		}
 		MarkCmdFailure(g.url, time.Since(start))
+		g.countFailure()
 		return nil, err
 	}
 
+	if p.optional && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		g.Logger().Debugf("API not available; status=%v", resp.Status)
+		MarkCmdSuccess(g.url, time.Since(start))
+		return nil, errNotAvailable
+	}
 	if resp.StatusCode >= 400 {
-		log.Errorf("error status from server; status=%v code=%v\n", resp.Status, resp.StatusCode)
+		g.Logger().Errorf("error status from server; status=%v code=%v\n", resp.Status, resp.StatusCode)
 		MarkCmdFailure(g.url, time.Since(start))
+		g.countFailure()
 		return nil, fmt.Errorf("error status received")
 	}
 
@@ -109,6 +169,7 @@ func NewNutanix(url, username, password string, maxParallelReq int) *Nutanix {
 		username:            username,
 		password:            password,
 		maxParallelRequests: maxParallelReq,
+		failures:            new(atomic.Uint64),
 	}
 	if nu.maxParallelRequests <= 0 {
 		nu.maxParallelRequests = MAX_PARALLEL_REQUESTS_DEFAULT
@@ -117,6 +178,60 @@ func NewNutanix(url, username, password string, maxParallelReq int) *Nutanix {
 	return &nu
 }
 
+// Fork returns a client of the same section counting its failed requests
+// apart, e.g. to tell which collector failed
+func (g *Nutanix) Fork() *Nutanix {
+	fork := *g
+	fork.failures = new(atomic.Uint64)
+	return &fork
+}
+
+// WithContext returns a copy of the client canceling its requests when ctx
+// is done, e.g. at the end of the scrape they belong to
+func (g *Nutanix) WithContext(ctx context.Context) *Nutanix {
+	c := *g
+	c.ctx = ctx
+	return &c
+}
+
+// WithLogger returns a copy of the client logging its requests and the
+// collectors using it to logger, e.g. at the log level of the section
+func (g *Nutanix) WithLogger(logger *log.Logger) *Nutanix {
+	c := *g
+	c.logger = logger
+	return &c
+}
+
+// Logger returns the logger of the client, the standard logger by default
+func (g *Nutanix) Logger() *log.Logger {
+	if g.logger == nil {
+		return log.StandardLogger()
+	}
+	return g.logger
+}
+
+// Context returns the context of the requests of the client
+func (g *Nutanix) Context() context.Context {
+	if g.ctx == nil {
+		return context.Background()
+	}
+	return g.ctx
+}
+
+// Failures returns the failed requests of the client
+func (g *Nutanix) Failures() uint64 {
+	if g.failures == nil {
+		return 0
+	}
+	return g.failures.Load()
+}
+
+func (g *Nutanix) countFailure() {
+	if g.failures != nil {
+		g.failures.Add(1)
+	}
+}
+
 // GetClusterUUID retrieves the cluster UUID from the Nutanix API
 func (g *Nutanix) GetClusterUUID() (string, error) {
 	resp, err := g.makeV2Request("GET", "/cluster/", nil)
@@ -1,6 +1,7 @@
 package nutanix
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -296,3 +297,40 @@ func TestHealthMetricsIntegration(t *testing.T) {
 
 	h.mu.RUnlock()
 }
+
+func TestForkCountsFailuresApart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	api := NewNutanix(server.URL, "user", "pass", 5)
+	fork := api.Fork()
+	_, err := fork.makeRequestWithParams("", "GET", "test", RequestParams{})
+	require.Error(t, err)
+
+	assert.Equal(t, uint64(1), fork.Failures())
+	assert.Equal(t, uint64(0), api.Failures())
+	assert.Equal(t, uint64(0), (&Nutanix{}).Failures())
+}
+
+func TestWithContextCancelsRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	base := NewNutanix(server.URL, "user", "pass", 5)
+	api := base.WithContext(ctx)
+	// The client it is derived from keeps its context
+	assert.Equal(t, context.Background(), base.Context())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := api.makeRequestWithParams("", "GET", "test", RequestParams{})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), HTTP_TIMEOUT)
+	assert.Equal(t, context.Background(), (&Nutanix{}).Context())
+}
@@ -0,0 +1,219 @@
+package nutanix
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	RECORDING_MODE_RECORD = "record"
+	RECORDING_MODE_REPLAY = "replay"
+	REDACTED              = "REDACTED"
+)
+
+// recordingIgnoredParams are left out of the request key, so that queries
+// for a time range relative to now can be replayed
+var recordingIgnoredParams = map[string]bool{
+	"start_time_in_usecs": true,
+	"end_time_in_usecs":   true,
+}
+
+// recordingIgnoredFields are left out of the JSON request bodies in the
+// request key, for the same reason
+var recordingIgnoredFields = map[string]bool{
+	"cut_off_time_usecs": true,
+}
+
+// recordingDefaultRedact lists the fields always redacted
+var recordingDefaultRedact = []string{"password", "nutanix_password"}
+
+var recordingFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Recording saves the Prism responses of a section to a directory, or
+// serves the saved responses instead of calling Prism. Credentials are never
+// recorded and the configured JSON fields are redacted.
+type Recording struct {
+	Mode   string   `yaml:"mode"`
+	Dir    string   `yaml:"dir"`
+	Redact []string `yaml:"redact"`
+}
+
+// recordedExchange is a request/response pair as saved in a file
+type recordedExchange struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	RequestBody json.RawMessage `json:"request_body,omitempty"`
+	Status      int             `json:"status"`
+	Body        json.RawMessage `json:"body"`
+}
+
+// Validate checks the recording config
+func (r *Recording) Validate() error {
+	switch r.Mode {
+	case RECORDING_MODE_RECORD, RECORDING_MODE_REPLAY:
+	default:
+		return fmt.Errorf("unknown recording mode %q", r.Mode)
+	}
+	if len(r.Dir) == 0 {
+		return fmt.Errorf("recording dir is required")
+	}
+	return nil
+}
+
+// WithRecording records or replays the requests of the client
+func (g *Nutanix) WithRecording(r *Recording) *Nutanix {
+	g.recording = r
+	return g
+}
+
+// requestKey returns the file name of a request, derived from the method,
+// the path, the query without the ignored params and the body without the
+// ignored fields
+func requestKey(method string, u *url.URL, body string) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if !recordingIgnoredParams[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(method + " " + u.Path + "?")
+	for _, key := range keys {
+		b.WriteString(key + "=" + strings.Join(query[key], ",") + "&")
+	}
+	b.WriteString("\n" + normalizeRequestBody(body))
+	sum := sha256.Sum256([]byte(b.String()))
+
+	name := recordingFileChars.ReplaceAllString(strings.Trim(strings.TrimPrefix(u.Path, "/PrismGateway/services/rest"), "/"), "_")
+	return fmt.Sprintf("%s_%s_%s.json", strings.ToLower(method), name, hex.EncodeToString(sum[:])[:12])
+}
+
+// normalizeRequestBody removes the ignored fields from a JSON request body.
+// Bodies which are not JSON are returned as-is.
+func normalizeRequestBody(body string) string {
+	var doc map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil || doc == nil {
+		return body
+	}
+	for field := range recordingIgnoredFields {
+		delete(doc, field)
+	}
+	// Marshal sorts the keys, the key does not depend on the field order
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return string(out)
+}
+
+// record saves the response and returns it with a fresh body
+func (r *Recording) record(req *http.Request, body string, resp *http.Response) (*http.Response, error) {
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	u := *req.URL
+	u.User = nil
+	exchange := recordedExchange{
+		Method:      req.Method,
+		URL:         u.RequestURI(),
+		RequestBody: r.redact([]byte(body)),
+		Status:      resp.StatusCode,
+		Body:        r.redact(data),
+	}
+	out, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir, requestKey(req.Method, req.URL, body)), out, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to record response: %w", err)
+	}
+	return resp, nil
+}
+
+// replay returns the recorded response of the request
+func (r *Recording) replay(req *http.Request, body string) (*http.Response, error) {
+	file := filepath.Join(r.Dir, requestKey(req.Method, req.URL, body))
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response for %s %s: %w", req.Method, req.URL.RequestURI(), err)
+	}
+	var exchange recordedExchange
+	if err := json.Unmarshal(data, &exchange); err != nil {
+		return nil, fmt.Errorf("invalid recorded response %s: %w", file, err)
+	}
+	respBody := []byte(exchange.Body)
+	var text string
+	if json.Unmarshal(exchange.Body, &text) == nil {
+		// Recorded body which is not JSON
+		respBody = []byte(text)
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", exchange.Status, http.StatusText(exchange.Status)),
+		StatusCode: exchange.Status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(respBody)),
+		Request:    req,
+	}, nil
+}
+
+// redact replaces the values of the redacted fields of a JSON document.
+// Documents which are not JSON are saved as JSON string.
+func (r *Recording) redact(data []byte) json.RawMessage {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Keep large numbers like usecs timestamps as they are
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		out, _ := json.Marshal(string(data))
+		return out
+	}
+	fields := make(map[string]bool)
+	for _, field := range append(append([]string{}, recordingDefaultRedact...), r.Redact...) {
+		fields[field] = true
+	}
+	out, _ := json.Marshal(redactValue(doc, fields))
+	return out
+}
+
+func redactValue(v interface{}, fields map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if fields[key] {
+				v[key] = REDACTED
+			} else {
+				v[key] = redactValue(value, fields)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactValue(value, fields)
+		}
+	}
+	return v
+}
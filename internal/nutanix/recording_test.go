@@ -0,0 +1,75 @@
+package nutanix
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"metadata": {"grand_total_entities": 1, "end_index": 1}, "entities": [
+			{"storage_container_uuid": "sc1", "cluster_uuid": "c1", "name": "default", "owner_password": "secret",
+			 "usage_stats": {"storage.user_capacity_bytes": 9007199254740993}}
+		]}`))
+	}))
+	dir := t.TempDir()
+
+	// Record a scrape of the storage containers collector
+	recordAPI := NewNutanix(server.URL, "prism-user", "prism-s3cr3t", 2).WithRecording(&Recording{Mode: RECORDING_MODE_RECORD, Dir: dir, Redact: []string{"owner_password"}})
+	recorded := testutil.CollectAndCount(NewStorageContainersCollector(recordAPI))
+	require.Greater(t, recorded, 0)
+	server.Close()
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasPrefix(files[0].Name(), "get_v2.0_storage_containers_"))
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.NotContains(t, string(data), "prism-user")
+	assert.NotContains(t, string(data), "prism-s3cr3t")
+	assert.Contains(t, string(data), "9007199254740993")
+
+	// Replay the scrape without Prism
+	replayAPI := NewNutanix(server.URL, "user", "pass", 2).WithRecording(&Recording{Mode: RECORDING_MODE_REPLAY, Dir: dir})
+	assert.Equal(t, recorded, testutil.CollectAndCount(NewStorageContainersCollector(replayAPI)))
+	assert.Equal(t, uint64(0), replayAPI.Failures())
+}
+
+func TestReplayMissing(t *testing.T) {
+	api := NewNutanix("http://prism.invalid", "user", "pass", 2).WithRecording(&Recording{Mode: RECORDING_MODE_REPLAY, Dir: t.TempDir()})
+	_, err := api.makeV2Request("GET", "/cluster/", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no recorded response")
+	assert.Equal(t, uint64(1), api.Failures())
+}
+
+func TestRequestKeyIgnoresTimeRange(t *testing.T) {
+	req1, _ := http.NewRequest("GET", "http://prism/PrismGateway/services/rest/v2.0/hosts/h1/stats/?metrics=a&start_time_in_usecs=1", nil)
+	req2, _ := http.NewRequest("GET", "http://prism/PrismGateway/services/rest/v2.0/hosts/h1/stats/?metrics=a&start_time_in_usecs=2", nil)
+	req3, _ := http.NewRequest("GET", "http://prism/PrismGateway/services/rest/v2.0/hosts/h1/stats/?metrics=b", nil)
+	assert.Equal(t, requestKey("GET", req1.URL, ""), requestKey("GET", req2.URL, ""))
+	assert.NotEqual(t, requestKey("GET", req1.URL, ""), requestKey("GET", req3.URL, ""))
+}
+
+func TestRequestKeyIgnoresTimeFieldsOfBody(t *testing.T) {
+	u, _ := url.Parse("http://prism/PrismGateway/services/rest/v2.0/tasks/list")
+	body1 := fmt.Sprintf(TASKS_LIST_REQUEST_BODY, 1000, TASKS_PAGE_SIZE)
+	body2 := fmt.Sprintf(TASKS_LIST_REQUEST_BODY, 2000, TASKS_PAGE_SIZE)
+	running := fmt.Sprintf(TASKS_RUNNING_REQUEST_BODY, TASKS_PAGE_SIZE)
+	assert.Equal(t, requestKey("POST", u, body1), requestKey("POST", u, body2))
+	assert.NotEqual(t, requestKey("POST", u, body1), requestKey("POST", u, running))
+	// Bodies which are not JSON are used as-is
+	assert.NotEqual(t, requestKey("POST", u, "a"), requestKey("POST", u, "b"))
+}
@@ -0,0 +1,326 @@
+package nutanix
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+const METRIC_SERIES_DROPPED = "nutanix_exporter_series_dropped_total"
+
+// SeriesLimits caps the series published for a section. Zero means no limit.
+type SeriesLimits struct {
+	Section    int            `yaml:"section"`
+	Collectors map[string]int `yaml:"collectors"`
+}
+
+// seriesPriorities lists metric prefixes by priority, series of the first
+// entries are dropped first. Series not matching any prefix are dropped last.
+var seriesPriorities = []string{
+	"nutanix_vmnics_",
+	"nutanix_hostnics_",
+	"nutanix_vdisks_",
+	"nutanix_volume_groups_vdisk_",
+}
+
+// seriesPriority returns the priority of a series, lower is dropped first
+func seriesPriority(name string) int {
+	for i, prefix := range seriesPriorities {
+		if strings.HasPrefix(name, prefix) {
+			return i
+		}
+	}
+	return len(seriesPriorities)
+}
+
+var (
+	seriesDroppedMu        sync.Mutex
+	seriesDroppedBySection = map[string]map[string]uint64{} // section -> collector -> dropped series
+)
+
+// SeriesGuard enforces the series limits of a section for one scrape. Every
+// collector is gathered from its own registry, so that the series can be
+// told apart by collector.
+type SeriesGuard struct {
+	section string
+	limits  SeriesLimits
+
+	mu         sync.Mutex
+	collectors []guardedCollector
+	families   map[string]string // metric name -> collector
+	dropped    map[string]int    // collector -> series dropped in this scrape
+	hit        []string          // limits hit in this scrape
+	logger     *log.Logger
+}
+
+// guardedCollector is the registry of a collector with its series limit
+type guardedCollector struct {
+	name     string
+	limit    int
+	registry *prometheus.Registry
+}
+
+// NewSeriesGuard - create the guard for a scrape of the section
+func NewSeriesGuard(_api *Nutanix, limits SeriesLimits) *SeriesGuard {
+	return &SeriesGuard{
+		section:  _api.url,
+		limits:   limits,
+		families: make(map[string]string),
+		dropped:  make(map[string]int),
+		logger:   _api.Logger(),
+	}
+}
+
+// MustRegister registers the collector with the series limit configured for
+// it. Panics like prometheus.Registry.MustRegister.
+func (g *SeriesGuard) MustRegister(name string, c prometheus.Collector) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	g.collectors = append(g.collectors, guardedCollector{name: name, limit: g.limits.Collectors[name], registry: registry})
+}
+
+// gather gathers the collector and applies its series limit
+func (g *SeriesGuard) gather(c guardedCollector) ([]*dto.MetricFamily, error) {
+	families, err := c.registry.Gather()
+
+	g.mu.Lock()
+	for _, family := range families {
+		g.families[family.GetName()] = c.name
+	}
+	g.mu.Unlock()
+
+	if c.limit > 0 {
+		var dropped int
+		families, dropped = dropSeries(families, c.limit)
+		if dropped > 0 {
+			g.drop(c.name, dropped, fmt.Sprintf("collector %s limit %d", c.name, c.limit))
+		}
+	}
+	return families, err
+}
+
+// dropSeries drops the lowest priority series until at most limit series are
+// left. Returns the remaining families and the number of dropped series.
+func dropSeries(families []*dto.MetricFamily, limit int) ([]*dto.MetricFamily, int) {
+	dropped, _ := dropSeriesByFamily(families, limit)
+	total := 0
+	for _, n := range dropped {
+		total += n
+	}
+	return nonEmptyFamilies(families), total
+}
+
+// dropSeriesByFamily drops the lowest priority series until at most limit
+// series are left. Returns the dropped series per family name.
+func dropSeriesByFamily(families []*dto.MetricFamily, limit int) (map[string]int, bool) {
+	total := 0
+	for _, family := range families {
+		total += len(family.Metric)
+	}
+	excess := total - limit
+	if excess <= 0 {
+		return nil, false
+	}
+
+	byPriority := make([]*dto.MetricFamily, len(families))
+	copy(byPriority, families)
+	sort.SliceStable(byPriority, func(i, j int) bool {
+		return seriesPriority(byPriority[i].GetName()) < seriesPriority(byPriority[j].GetName())
+	})
+	dropped := make(map[string]int)
+	for _, family := range byPriority {
+		if excess <= 0 {
+			break
+		}
+		n := len(family.Metric)
+		if n > excess {
+			n = excess
+		}
+		family.Metric = family.Metric[:len(family.Metric)-n]
+		excess -= n
+		dropped[family.GetName()] += n
+	}
+	return dropped, true
+}
+
+// nonEmptyFamilies returns the families with at least one series
+func nonEmptyFamilies(families []*dto.MetricFamily) []*dto.MetricFamily {
+	kept := families[:0]
+	for _, family := range families {
+		if len(family.Metric) > 0 {
+			kept = append(kept, family)
+		}
+	}
+	return kept
+}
+
+// drop records series dropped because of a limit
+func (g *SeriesGuard) drop(collector string, count int, limit string) {
+	g.mu.Lock()
+	g.dropped[collector] += count
+	g.hit = append(g.hit, limit)
+	g.mu.Unlock()
+
+	seriesDroppedMu.Lock()
+	defer seriesDroppedMu.Unlock()
+	dropped, ok := seriesDroppedBySection[g.section]
+	if !ok {
+		dropped = make(map[string]uint64)
+		seriesDroppedBySection[g.section] = dropped
+	}
+	dropped[collector] += uint64(count)
+}
+
+// Gatherer returns a gatherer of the registered collectors and the given
+// gatherer, applying the series limits and publishing the dropped series.
+// The collectors are gathered concurrently.
+func (g *SeriesGuard) Gatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		g.reset()
+		results := make([][]*dto.MetricFamily, len(g.collectors)+1)
+		errs := make([]error, len(g.collectors)+1)
+		var wg sync.WaitGroup
+		for i, c := range g.collectors {
+			wg.Add(1)
+			go func(i int, c guardedCollector) {
+				defer wg.Done()
+				results[i], errs[i] = g.gather(c)
+			}(i, c)
+		}
+		results[len(g.collectors)], errs[len(g.collectors)] = gatherer.Gather()
+		wg.Wait()
+
+		families, mergeErr := mergeFamilies(results, g.logger)
+		if g.limits.Section > 0 {
+			families = g.limitSection(families)
+		}
+		if len(g.hit) > 0 {
+			g.logger.Warnf("Series limits hit for section %s: %s, dropped series per collector: %v", g.section, strings.Join(g.hit, ", "), g.dropped)
+		}
+		if dropped := g.droppedFamily(); dropped != nil {
+			families = append(families, dropped)
+		}
+
+		var multiErr prometheus.MultiError
+		for _, err := range append(errs, mergeErr) {
+			multiErr.Append(err)
+		}
+		return families, multiErr.MaybeUnwrap()
+	})
+}
+
+// reset clears the state of the previous scrape
+func (g *SeriesGuard) reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.families = make(map[string]string)
+	g.dropped = make(map[string]int)
+	g.hit = nil
+}
+
+// mergeFamilies merges the families of several gatherers, sorted by name.
+// Families inconsistent with a family of the same name merged before are
+// dropped, like a single registry would reject them.
+func mergeFamilies(results [][]*dto.MetricFamily, logger *log.Logger) ([]*dto.MetricFamily, error) {
+	var errs prometheus.MultiError
+	byName := make(map[string]*dto.MetricFamily)
+	for _, families := range results {
+		for _, family := range families {
+			merged, ok := byName[family.GetName()]
+			if !ok {
+				byName[family.GetName()] = family
+				continue
+			}
+			if err := checkFamilyConsistency(merged, family); err != nil {
+				logger.Errorf("Dropping inconsistent metric family: %v", err)
+				errs.Append(err)
+				continue
+			}
+			merged.Metric = append(merged.Metric, family.Metric...)
+		}
+	}
+	families := make([]*dto.MetricFamily, 0, len(byName))
+	for _, family := range byName {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].GetName() < families[j].GetName() })
+	return families, errs.MaybeUnwrap()
+}
+
+// checkFamilyConsistency returns an error if the families of the same name
+// differ in help, type or label names
+func checkFamilyConsistency(a, b *dto.MetricFamily) error {
+	switch {
+	case a.GetType() != b.GetType():
+		return fmt.Errorf("%s has types %s and %s", a.GetName(), a.GetType(), b.GetType())
+	case a.GetHelp() != b.GetHelp():
+		return fmt.Errorf("%s has help texts %q and %q", a.GetName(), a.GetHelp(), b.GetHelp())
+	}
+	if len(a.Metric) == 0 || len(b.Metric) == 0 {
+		return nil
+	}
+	labelNames := func(m *dto.Metric) string {
+		names := make([]string, 0, len(m.Label))
+		for _, label := range m.Label {
+			names = append(names, label.GetName())
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+	if la, lb := labelNames(a.Metric[0]), labelNames(b.Metric[0]); la != lb {
+		return fmt.Errorf("%s has label names [%s] and [%s]", a.GetName(), la, lb)
+	}
+	return nil
+}
+
+// limitSection drops the lowest priority series until the section limit is met
+func (g *SeriesGuard) limitSection(families []*dto.MetricFamily) []*dto.MetricFamily {
+	droppedByFamily, ok := dropSeriesByFamily(families, g.limits.Section)
+	if !ok {
+		return families
+	}
+	dropped := make(map[string]int)
+	for name, n := range droppedByFamily {
+		collector, ok := g.families[name]
+		if !ok {
+			collector = "unknown"
+		}
+		dropped[collector] += n
+	}
+	for collector, n := range dropped {
+		g.drop(collector, n, fmt.Sprintf("section limit %d", g.limits.Section))
+	}
+	return nonEmptyFamilies(families)
+}
+
+// droppedFamily returns the dropped series of the section since exporter start
+func (g *SeriesGuard) droppedFamily() *dto.MetricFamily {
+	seriesDroppedMu.Lock()
+	defer seriesDroppedMu.Unlock()
+	dropped := seriesDroppedBySection[g.section]
+	if len(dropped) == 0 {
+		return nil
+	}
+
+	name := METRIC_SERIES_DROPPED
+	help := "Series dropped because of series limits since exporter start"
+	family := &dto.MetricFamily{Name: &name, Help: &help, Type: dto.MetricType_COUNTER.Enum()}
+	collectors := make([]string, 0, len(dropped))
+	for collector := range dropped {
+		collectors = append(collectors, collector)
+	}
+	sort.Strings(collectors)
+	for _, collector := range collectors {
+		labelName, labelValue, value := "collector", collector, float64(dropped[collector])
+		family.Metric = append(family.Metric, &dto.Metric{
+			Label:   []*dto.LabelPair{{Name: &labelName, Value: &labelValue}},
+			Counter: &dto.Counter{Value: &value},
+		})
+	}
+	return family
+}
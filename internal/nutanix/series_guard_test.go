@@ -0,0 +1,142 @@
+package nutanix
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seriesCollector publishes a number of series per metric name
+type seriesCollector map[string]int
+
+func (c seriesCollector) Describe(ch chan<- *prometheus.Desc) {
+	for name := range c {
+		ch <- prometheus.NewDesc(name, "test", []string{"id"}, nil)
+	}
+}
+
+func (c seriesCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, n := range c {
+		desc := prometheus.NewDesc(name, "test", []string{"id"}, nil)
+		for i := 0; i < n; i++ {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, string(rune('a'+i)))
+		}
+	}
+}
+
+func countSeries(t *testing.T, g prometheus.Gatherer) map[string]int {
+	families, err := g.Gather()
+	require.NoError(t, err)
+	counts := make(map[string]int)
+	for _, family := range families {
+		counts[family.GetName()] = len(family.Metric)
+	}
+	return counts
+}
+
+func TestSeriesGuardCollectorLimit(t *testing.T) {
+	// Reset global state
+	seriesDroppedBySection = map[string]map[string]uint64{}
+
+	guard := NewSeriesGuard(&Nutanix{url: "test"}, SeriesLimits{Collectors: map[string]int{"vms": 5}})
+	registry := prometheus.NewRegistry()
+	guard.MustRegister("vms", seriesCollector{"nutanix_vms_memory_usage_bytes": 3, "nutanix_vmnics_network_received_bytes": 4})
+	guard.MustRegister("hosts", seriesCollector{"nutanix_hosts_num_vms": 3})
+
+	counts := countSeries(t, guard.Gatherer(registry))
+	// NIC detail is dropped first
+	assert.Equal(t, 3, counts["nutanix_vms_memory_usage_bytes"])
+	assert.Equal(t, 2, counts["nutanix_vmnics_network_received_bytes"])
+	assert.Equal(t, 3, counts["nutanix_hosts_num_vms"])
+	assert.Equal(t, 1, counts[METRIC_SERIES_DROPPED])
+	assert.Equal(t, uint64(2), seriesDroppedBySection["test"]["vms"])
+}
+
+func TestSeriesGuardSectionLimit(t *testing.T) {
+	// Reset global state
+	seriesDroppedBySection = map[string]map[string]uint64{}
+
+	guard := NewSeriesGuard(&Nutanix{url: "test"}, SeriesLimits{Section: 6})
+	registry := prometheus.NewRegistry()
+	guard.MustRegister("vms", seriesCollector{"nutanix_vms_memory_usage_bytes": 3})
+	guard.MustRegister("virtual_disks", seriesCollector{"nutanix_vdisks_controller_num_read_io": 4})
+	guard.MustRegister("hosts", seriesCollector{"nutanix_hosts_num_vms": 2})
+
+	counts := countSeries(t, guard.Gatherer(registry))
+	assert.Equal(t, 3, counts["nutanix_vms_memory_usage_bytes"])
+	assert.Equal(t, 1, counts["nutanix_vdisks_controller_num_read_io"])
+	assert.Equal(t, 2, counts["nutanix_hosts_num_vms"])
+	assert.Equal(t, uint64(3), seriesDroppedBySection["test"]["virtual_disks"])
+}
+
+func TestSeriesGuardNoLimits(t *testing.T) {
+	// Reset global state
+	seriesDroppedBySection = map[string]map[string]uint64{}
+
+	guard := NewSeriesGuard(&Nutanix{url: "test"}, SeriesLimits{})
+	registry := prometheus.NewRegistry()
+	guard.MustRegister("vms", seriesCollector{"nutanix_vmnics_network_received_bytes": 10})
+
+	counts := countSeries(t, guard.Gatherer(registry))
+	assert.Equal(t, 10, counts["nutanix_vmnics_network_received_bytes"])
+	_, ok := counts[METRIC_SERIES_DROPPED]
+	assert.False(t, ok)
+}
+
+// helpCollector publishes one series of a metric with the given help
+type helpCollector struct {
+	name, help string
+	labels     []string
+}
+
+func (c helpCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc(c.name, c.help, c.labels, nil)
+}
+
+func (c helpCollector) Collect(ch chan<- prometheus.Metric) {
+	values := make([]string, len(c.labels))
+	for i := range values {
+		values[i] = c.help
+	}
+	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(c.name, c.help, c.labels, nil), prometheus.GaugeValue, 1, values...)
+}
+
+func TestSeriesGuardInconsistentFamilies(t *testing.T) {
+	guard := NewSeriesGuard(&Nutanix{url: "test"}, SeriesLimits{})
+	guard.MustRegister("vms", helpCollector{"nutanix_shared", "first", []string{"id"}})
+	guard.MustRegister("hosts", helpCollector{"nutanix_shared", "second", []string{"id"}})
+	guard.MustRegister("cluster", helpCollector{"nutanix_labels", "same", []string{"id"}})
+	guard.MustRegister("virtual_disks", helpCollector{"nutanix_labels", "same", []string{"uuid"}})
+	guard.MustRegister("snapshots", helpCollector{"nutanix_consistent", "same", []string{"id"}})
+	guard.MustRegister("images", helpCollector{"nutanix_consistent", "same", []string{"id"}})
+
+	families, err := guard.Gatherer(prometheus.NewRegistry()).Gather()
+	require.Error(t, err)
+	counts := make(map[string]int)
+	for _, family := range families {
+		counts[family.GetName()] = len(family.Metric)
+	}
+	// Only the first of the inconsistent families is kept
+	assert.Equal(t, 1, counts["nutanix_shared"])
+	assert.Equal(t, 1, counts["nutanix_labels"])
+	assert.Equal(t, 2, counts["nutanix_consistent"])
+}
+
+func TestSeriesGuardGatherTwice(t *testing.T) {
+	// Reset global state
+	seriesDroppedBySection = map[string]map[string]uint64{}
+
+	guard := NewSeriesGuard(&Nutanix{url: "test"}, SeriesLimits{Collectors: map[string]int{"vms": 5}})
+	guard.MustRegister("vms", seriesCollector{"nutanix_vmnics_network_received_bytes": 7})
+
+	gatherer := guard.Gatherer(prometheus.NewRegistry())
+	countSeries(t, gatherer)
+	assert.Equal(t, map[string]int{"vms": 2}, guard.dropped)
+	// The series dropped in a scrape are not carried over to the next one
+	countSeries(t, gatherer)
+	assert.Equal(t, map[string]int{"vms": 2}, guard.dropped)
+	assert.Len(t, guard.hit, 1)
+	assert.Equal(t, uint64(4), seriesDroppedBySection["test"]["vms"])
+}
@@ -11,7 +11,6 @@ package nutanix
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
 // SnapshotsExporter
@@ -30,11 +29,7 @@ func (e *SnapshotsExporter) Describe(ch chan<- *prometheus.Desc) {
 	e.metrics["count"].Describe(ch)
 
 	for _, key := range e.fields {
-		e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: e.namespace,
-			Name:      key, Help: "..."}, []string{"snapshot_uuid", "snapshot_name", "vm_uuid", "vm_name"})
-
-		e.metrics[key].Describe(ch)
+		e.describeStat(ch, key, []string{"snapshot_uuid", "snapshot_name", "vm_uuid", "vm_name"})
 	}
 }
 
@@ -44,7 +39,7 @@ func (e *SnapshotsExporter) Collect(ch chan<- prometheus.Metric) {
 	entities, err := e.api.fetchAllPages("/snapshots", nil)
 	if err != nil {
 		e.result = nil
-		log.Error("Snapshots discovery failed")
+		e.api.Logger().Error("Snapshots discovery failed")
 		return
 	}
 
@@ -54,7 +49,7 @@ func (e *SnapshotsExporter) Collect(ch chan<- prometheus.Metric) {
 	g.Set(float64(len(entities)))
 	g.Collect(ch)
 
-	log.Debugf("Results: %d", len(entities))
+	e.api.Logger().Debugf("Results: %d", len(entities))
 	for _, entRaw := range entities {
 		ent := entRaw.(map[string]interface{})
 		vm_details := ent["vm_create_spec"].(map[string]interface{})
@@ -65,11 +60,9 @@ func (e *SnapshotsExporter) Collect(ch chan<- prometheus.Metric) {
 		vm_name := vm_details["name"].(string)
 
 		for _, key := range e.fields {
-			g := e.metrics[key].WithLabelValues(snapshot_uuid, snapshot_name, vm_uuid, vm_name)
-			g.Set(e.valueToFloat64(ent[key]))
-			g.Collect(ch)
+			e.collectStat(ch, key, e.valueToFloat64(ent[key]), snapshot_uuid, snapshot_name, vm_uuid, vm_name)
 		}
-		log.Debugf("Snapshot data collected for name=%s, uuid=%s", snapshot_name, snapshot_uuid)
+		e.api.Logger().Debugf("Snapshot data collected for name=%s, uuid=%s", snapshot_name, snapshot_uuid)
 	}
 }
 
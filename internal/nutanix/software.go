@@ -0,0 +1,246 @@
+package nutanix
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	KEY_SOFTWARE_VERSION        = "version_info"
+	KEY_SOFTWARE_HYPERVISOR     = "hypervisor_version_info"
+	METRIC_SOFTWARE_UPGRADE     = "upgrade_in_progress"
+	METRIC_SOFTWARE_UPDATES     = "available_updates"
+	LCM_UPDATE_STATUS_AVAILABLE = "AVAILABLE"
+	// LCM_PROBE_INTERVAL is the time before a cluster without the LCM v4 API
+	// is asked again, LCM may be upgraded in the meantime
+	LCM_PROBE_INTERVAL = time.Hour
+)
+
+// lcmUnavailableSince holds per section when the LCM v4 API was found
+// missing. Collectors are created per scrape, so it has to outlive them.
+var (
+	lcmUnavailableMu    sync.Mutex
+	lcmUnavailableSince = map[string]time.Time{}
+)
+
+// SoftwareExporter
+type SoftwareExporter struct {
+	*nutanixExporter
+	hosts       []interface{}
+	lcmConfig   map[string]interface{}
+	lcmStatus   map[string]interface{}
+	lcmEntities []interface{}
+	now         func() time.Time
+}
+
+// lcmAvailable returns false if the LCM v4 API was found missing on the
+// cluster within the probe interval
+func (e *SoftwareExporter) lcmAvailable() bool {
+	lcmUnavailableMu.Lock()
+	defer lcmUnavailableMu.Unlock()
+	since, ok := lcmUnavailableSince[e.api.url]
+	return !ok || e.now().Sub(since) >= LCM_PROBE_INTERVAL
+}
+
+// setLCMAvailable records whether the LCM v4 API is available on the cluster
+func (e *SoftwareExporter) setLCMAvailable(available bool) {
+	lcmUnavailableMu.Lock()
+	defer lcmUnavailableMu.Unlock()
+	if available {
+		delete(lcmUnavailableSince, e.api.url)
+	} else {
+		lcmUnavailableSince[e.api.url] = e.now()
+	}
+}
+
+// fetchLCM reads the "data" block of an LCM v4 resource into v. LCM is not
+// available on every cluster, failures only skip the LCM metrics. A cluster
+// without the LCM v4 API is not asked again within the probe interval.
+func (e *SoftwareExporter) fetchLCM(resource string, v interface{}) bool {
+	if !e.lcmAvailable() {
+		return false
+	}
+	resp, err := e.api.makeV4RequestWithParams("GET", PRISM_API_PATH_LCM_V4, resource, RequestParams{optional: true})
+	if errors.Is(err, errNotAvailable) {
+		e.api.Logger().Debugf("LCM v4 API not available, skipping the LCM metrics for %s", LCM_PROBE_INTERVAL)
+		e.setLCMAvailable(false)
+		return false
+	}
+	if err != nil {
+		e.api.Logger().Debugf("LCM %s discovery failed: %v", resource, err)
+		return false
+	}
+	e.setLCMAvailable(true)
+	defer resp.Body.Close()
+
+	var result struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		e.api.Logger().Errorf("Failed to decode LCM %s response", resource)
+		return false
+	}
+	if err := json.Unmarshal(result.Data, v); err != nil {
+		e.api.Logger().Errorf("Failed to decode LCM %s data", resource)
+		return false
+	}
+	return true
+}
+
+// Describe - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *SoftwareExporter) Describe(ch chan<- *prometheus.Desc) {
+	resp, err := e.api.makeV2Request("GET", "/cluster/", nil)
+	if err != nil {
+		e.result = nil
+		e.api.Logger().Error("Cluster discovery for software versions failed")
+		return
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&e.result); err != nil {
+		e.result = nil
+		e.api.Logger().Error("Failed to decode cluster response")
+		return
+	}
+
+	e.hosts, err = e.api.fetchAllPages("/hosts", nil)
+	if err != nil {
+		e.hosts = nil
+		e.api.Logger().Error("Host discovery for software versions failed")
+	}
+
+	if !e.fetchLCM("config", &e.lcmConfig) {
+		e.lcmConfig = nil
+	}
+	if !e.fetchLCM("status", &e.lcmStatus) {
+		e.lcmStatus = nil
+	}
+	if !e.fetchLCM("entities", &e.lcmEntities) {
+		e.lcmEntities = nil
+	}
+
+	e.metrics[KEY_SOFTWARE_VERSION] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      KEY_SOFTWARE_VERSION,
+		Help:      "Software version of a cluster component (aos, ncc, lcm)"}, []string{"cluster_uuid", "component", "version"})
+	e.metrics[KEY_SOFTWARE_VERSION].Describe(ch)
+
+	e.metrics[KEY_SOFTWARE_HYPERVISOR] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      KEY_SOFTWARE_HYPERVISOR,
+		Help:      "Hypervisor version of a host"}, []string{"cluster_uuid", "uuid", "name", "version"})
+	e.metrics[KEY_SOFTWARE_HYPERVISOR].Describe(ch)
+
+	e.metrics[METRIC_SOFTWARE_UPGRADE] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_SOFTWARE_UPGRADE,
+		Help:      "Upgrade or LCM operation in progress"}, []string{"cluster_uuid", "component", "operation"})
+	e.metrics[METRIC_SOFTWARE_UPGRADE].Describe(ch)
+
+	e.metrics[METRIC_SOFTWARE_UPDATES] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_SOFTWARE_UPDATES,
+		Help:      "Count entities with an available update in the LCM inventory"}, []string{"cluster_uuid"})
+	e.metrics[METRIC_SOFTWARE_UPDATES].Describe(ch)
+}
+
+// Collect - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *SoftwareExporter) Collect(ch chan<- prometheus.Metric) {
+	if e.result == nil {
+		return
+	}
+	clusterUUID, _ := e.result["uuid"].(string)
+
+	versions := map[string]string{}
+	if version, ok := e.result["full_version"].(string); ok && len(version) > 0 {
+		versions["aos"] = version
+	} else if version, ok := e.result["version"].(string); ok {
+		versions["aos"] = version
+	}
+	if version, ok := e.result["ncc_version"].(string); ok {
+		versions["ncc"] = version
+	}
+	if version, ok := e.lcmConfig["version"].(string); ok {
+		versions["lcm"] = version
+	}
+	for component, version := range versions {
+		g := e.metrics[KEY_SOFTWARE_VERSION].WithLabelValues(clusterUUID, component, version)
+		g.Set(1)
+		g.Collect(ch)
+	}
+
+	for _, hostRaw := range e.hosts {
+		host := hostRaw.(map[string]interface{})
+		uuid, _ := host["uuid"].(string)
+		name, _ := host["name"].(string)
+		version, _ := host["hypervisor_full_name"].(string)
+		g := e.metrics[KEY_SOFTWARE_HYPERVISOR].WithLabelValues(clusterUUID, uuid, name, version)
+		g.Set(1)
+		g.Collect(ch)
+	}
+
+	// AOS reports a target version differing from the running version while upgrading
+	var aosUpgrade float64 = 0
+	if target, ok := e.result["target_version"].(string); ok && len(target) > 0 && target != e.result["version"] {
+		aosUpgrade = 1
+	}
+	g := e.metrics[METRIC_SOFTWARE_UPGRADE].WithLabelValues(clusterUUID, "aos", "upgrade")
+	g.Set(aosUpgrade)
+	g.Collect(ch)
+
+	if e.lcmStatus != nil {
+		operation := ""
+		if inProgress, ok := e.lcmStatus["inProgressOperation"].(map[string]interface{}); ok {
+			operation, _ = inProgress["operationType"].(string)
+		}
+		var lcmInProgress float64 = 0
+		if len(operation) > 0 {
+			lcmInProgress = 1
+		}
+		g := e.metrics[METRIC_SOFTWARE_UPGRADE].WithLabelValues(clusterUUID, "lcm", operation)
+		g.Set(lcmInProgress)
+		g.Collect(ch)
+	}
+
+	if e.lcmEntities != nil {
+		var updates float64 = 0
+		for _, entRaw := range e.lcmEntities {
+			ent, ok := entRaw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			available, _ := ent["availableVersions"].([]interface{})
+			for _, versionRaw := range available {
+				version, ok := versionRaw.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if status, ok := version["status"].(string); !ok || status == LCM_UPDATE_STATUS_AVAILABLE {
+					updates++
+					break
+				}
+			}
+		}
+		g := e.metrics[METRIC_SOFTWARE_UPDATES].WithLabelValues(clusterUUID)
+		g.Set(updates)
+		g.Collect(ch)
+	}
+	e.api.Logger().Debug("Software data collected for cluster UUID : ", clusterUUID)
+}
+
+// NewSoftwareCollector
+func NewSoftwareCollector(_api *Nutanix) *SoftwareExporter {
+	return &SoftwareExporter{
+		now: time.Now,
+		nutanixExporter: &nutanixExporter{
+			api:       *_api,
+			metrics:   make(map[string]*prometheus.GaugeVec),
+			namespace: "nutanix_software",
+		},
+	}
+}
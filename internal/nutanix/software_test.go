@@ -0,0 +1,51 @@
+package nutanix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoftwareWithoutLCM(t *testing.T) {
+	var lcmRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/lcm/"):
+			atomic.AddInt32(&lcmRequests, 1)
+			http.NotFound(w, r)
+		case strings.HasSuffix(r.URL.Path, "/cluster/"):
+			w.Write([]byte(`{"uuid": "c1", "version": "6.5", "ncc_version": "4.6"}`))
+		default:
+			w.Write([]byte(`{"metadata": {"grand_total_entities": 0}, "entities": []}`))
+		}
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	scrape := func() (*Nutanix, int) {
+		api := NewNutanix(server.URL, "user", "pass", 2)
+		collector := NewSoftwareCollector(api)
+		collector.now = func() time.Time { return now }
+		return api, testutil.CollectAndCount(collector, "nutanix_software_version_info")
+	}
+
+	// The missing LCM API is neither a failed request nor asked again
+	api, versions := scrape()
+	assert.Equal(t, 2, versions)
+	assert.Equal(t, uint64(0), api.Failures())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lcmRequests))
+	api, _ = scrape()
+	assert.Equal(t, uint64(0), api.Failures())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lcmRequests))
+
+	// Probed again after the probe interval
+	now = now.Add(LCM_PROBE_INTERVAL)
+	scrape()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&lcmRequests))
+}
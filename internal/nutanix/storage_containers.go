@@ -13,8 +13,6 @@ import (
 	"fmt"
 	"strconv"
 
-	log "github.com/sirupsen/logrus"
-
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -32,9 +30,10 @@ func (e *StorageContainerExporter) Describe(ch chan<- *prometheus.Desc) {
 	entities, err := e.api.fetchAllPages("/storage_containers", nil)
 	if err != nil {
 		e.result = nil
-		log.Error("Storage container discovery failed")
+		e.api.Logger().Error("Storage container discovery failed")
 		return
 	}
+	entities = e.filter.filterEntities(entities, storageContainerFilterKeys)
 
 	e.result = map[string]interface{}{"entities": entities}
 
@@ -54,41 +53,30 @@ func (e *StorageContainerExporter) Describe(ch chan<- *prometheus.Desc) {
 
 		// Publish host properties as separate record
 		key := KEY_STORAGE_CONTAINER_PROPERTIES
-		e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: e.namespace,
-			Name:      key, Help: "..."}, e.properties)
-		e.metrics[key].Describe(ch)
+		e.describeProperties(ch, key, e.properties)
 
 		if usageStats != nil {
 			for key := range usageStats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
-				key = e.normalizeKey(key)
-
-				e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-					Namespace: e.namespace,
-					Name:      key, Help: "..."}, []string{"storage_container_uuid", "cluster_uuid"})
-
-				e.metrics[key].Describe(ch)
+				e.describeStat(ch, key, []string{"storage_container_uuid", "cluster_uuid"})
 			}
 		}
 		if stats != nil {
 			e.addCalculatedStats(stats)
 			for key := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
-				key = e.normalizeKey(key)
-				e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-					Namespace: e.namespace,
-					Name:      key, Help: "..."}, []string{"storage_container_uuid", "cluster_uuid"})
-
-				e.metrics[key].Describe(ch)
+				e.describeStat(ch, key, []string{"storage_container_uuid", "cluster_uuid"})
 			}
 		}
+		for _, key := range e.allFields() {
+			e.describeStat(ch, key, []string{"storage_container_uuid", "cluster_uuid"})
+		}
 	}
 }
 
@@ -163,13 +151,11 @@ func (e *StorageContainerExporter) Collect(ch chan<- prometheus.Metric) {
 			}
 			property_values = append(property_values, val)
 		}
-		g := e.metrics[key].WithLabelValues(property_values...)
-		g.Set(1)
-		g.Collect(ch)
+		e.collectProperties(ch, key, property_values)
 
 		if usageStats != nil {
 			for key, value := range usageStats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
@@ -178,15 +164,12 @@ func (e *StorageContainerExporter) Collect(ch chan<- prometheus.Metric) {
 				if val == -1 {
 					continue
 				}
-				key = e.normalizeKey(key)
-				g := e.metrics[key].WithLabelValues(ent["storage_container_uuid"].(string), ent["cluster_uuid"].(string))
-				g.Set(val)
-				g.Collect(ch)
+				e.collectStat(ch, key, val, ent["storage_container_uuid"].(string), ent["cluster_uuid"].(string))
 			}
 		}
 		if stats != nil {
 			for key, value := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
@@ -195,13 +178,13 @@ func (e *StorageContainerExporter) Collect(ch chan<- prometheus.Metric) {
 				if val == -1 {
 					continue
 				}
-				key = e.normalizeKey(key)
-				g := e.metrics[key].WithLabelValues(ent["storage_container_uuid"].(string), ent["cluster_uuid"].(string))
-				g.Set(val)
-				g.Collect(ch)
+				e.collectStat(ch, key, val, ent["storage_container_uuid"].(string), ent["cluster_uuid"].(string))
 			}
 		}
-		log.Debugf("Storage data collected for storage: %s (UUID: %s)", ent["name"].(string), ent["storage_container_uuid"].(string))
+		for _, key := range e.allFields() {
+			e.collectStat(ch, key, e.valueToFloat64(ent[key]), ent["storage_container_uuid"].(string), ent["cluster_uuid"].(string))
+		}
+		e.api.Logger().Debugf("Storage data collected for storage: %s (UUID: %s)", ent["name"].(string), ent["storage_container_uuid"].(string))
 	}
 }
 
@@ -210,10 +193,12 @@ func NewStorageContainersCollector(_api *Nutanix) *StorageContainerExporter {
 
 	return &StorageContainerExporter{
 		&nutanixExporter{
-			api:        *_api,
-			metrics:    make(map[string]*prometheus.GaugeVec),
-			namespace:  "nutanix_storage_containers",
-			properties: []string{"storage_container_uuid", "cluster_uuid", "name", "replication_factor", "compression_enabled", "max_capacity_mb"},
+			api:                *_api,
+			metrics:            make(map[string]*prometheus.GaugeVec),
+			namespace:          "nutanix_storage_containers",
+			properties:         []string{"storage_container_uuid", "cluster_uuid", "name", "replication_factor", "compression_enabled", "max_capacity_mb"},
+			volatileProperties: map[string]bool{"replication_factor": true, "compression_enabled": true, "max_capacity_mb": true},
+			volatileFields:     []string{"replication_factor", "compression_enabled", "max_capacity"},
 			filter_stats: map[string]bool{
 				"storage.usage_bytes":                       true,
 				"storage.capacity_bytes":                    true,
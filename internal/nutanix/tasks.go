@@ -0,0 +1,272 @@
+package nutanix
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	METRIC_TASKS_COUNT      = "count"
+	METRIC_TASKS_OLDEST_AGE = "oldest_running_age_seconds"
+	TASKS_WINDOW            = time.Hour
+	TASKS_PAGE_SIZE         = 1000
+	TASK_STATUS_RUNNING     = "Running"
+	TASK_STATUS_FAILED      = "Failed"
+	TASKS_LIST_REQUEST_BODY = `{"cut_off_time_usecs": %d, "include_completed": true, "include_subtasks_info": false, "count": %d}`
+	// Running tasks are queried without cut off, to report tasks stuck for
+	// longer than the window
+	TASKS_RUNNING_REQUEST_BODY = `{"include_completed": false, "include_subtasks_info": false, "count": %d}`
+)
+
+// tasksState keeps the failed tasks counted since exporter start per section.
+// Collectors are created per scrape, so the state has to outlive them.
+type tasksState struct {
+	mu sync.Mutex
+	// failed task uuid -> last scrape returning it, used to count each failed
+	// task once
+	seenFailed map[string]time.Time
+	// failed tasks per operation type since exporter start
+	failed map[string]uint64
+	// tasks not completed in the last scrape
+	running map[string]bool
+}
+
+var (
+	tasksMu        sync.Mutex
+	tasksBySection = map[string]*tasksState{}
+)
+
+func getTasksState(section string) *tasksState {
+	tasksMu.Lock()
+	defer tasksMu.Unlock()
+	s, ok := tasksBySection[section]
+	if !ok {
+		s = &tasksState{
+			seenFailed: make(map[string]time.Time),
+			failed:     make(map[string]uint64),
+			running:    make(map[string]bool),
+		}
+		tasksBySection[section] = s
+	}
+	return s
+}
+
+// recordFailed counts failed tasks not seen before and forgets tasks not
+// returned for longer than the query window. Returns a copy of the failed
+// counters.
+func (s *tasksState) recordFailed(tasks []map[string]interface{}, now time.Time, window time.Duration) map[string]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, task := range tasks {
+		if task["progress_status"] != TASK_STATUS_FAILED {
+			continue
+		}
+		uuid, ok := task["uuid"].(string)
+		if !ok {
+			continue
+		}
+		_, seen := s.seenFailed[uuid]
+		s.seenFailed[uuid] = now
+		if !seen {
+			operation, _ := task["operation_type"].(string)
+			s.failed[operation]++
+		}
+	}
+
+	for uuid, lastSeen := range s.seenFailed {
+		if lastSeen.Before(now.Add(-window)) {
+			delete(s.seenFailed, uuid)
+		}
+	}
+
+	failed := make(map[string]uint64, len(s.failed))
+	for operation, count := range s.failed {
+		failed[operation] = count
+	}
+	return failed
+}
+
+// completedOutsideWindow returns the tasks not completed in the last scrape
+// that are neither running nor returned by the windowed query anymore: tasks
+// created before the window that completed since the last scrape. The tasks
+// not completed are remembered for the next scrape.
+func (s *tasksState) completedOutsideWindow(tasks, running []map[string]interface{}) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	returned := make(map[string]bool, len(tasks)+len(running))
+	for _, list := range [][]map[string]interface{}{tasks, running} {
+		for _, task := range list {
+			if uuid, ok := task["uuid"].(string); ok {
+				returned[uuid] = true
+			}
+		}
+	}
+	completed := []string{}
+	for uuid := range s.running {
+		if !returned[uuid] {
+			completed = append(completed, uuid)
+		}
+	}
+	sort.Strings(completed)
+
+	s.running = make(map[string]bool, len(running))
+	for _, task := range running {
+		if uuid, ok := task["uuid"].(string); ok {
+			s.running[uuid] = true
+		}
+	}
+	return completed
+}
+
+func usecsToTime(value interface{}) time.Time {
+	usecs, _ := value.(float64)
+	return time.UnixMicro(int64(usecs))
+}
+
+var descTasksFailed = prometheus.NewDesc("nutanix_tasks_failed_total", "Failed tasks observed since exporter start", []string{"operation_type"}, nil)
+
+// TasksExporter
+type TasksExporter struct {
+	*nutanixExporter
+	tasks   []map[string]interface{}
+	running []map[string]interface{}
+	failed  map[string]uint64
+	now     func() time.Time
+}
+
+func (e *TasksExporter) fetchTasks(body string) ([]map[string]interface{}, error) {
+	resp, err := e.api.makeV2PostRequest("/tasks/list", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Entities []map[string]interface{} `json:"entities"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Entities) >= TASKS_PAGE_SIZE {
+		e.api.Logger().Warnf("Task list truncated to %d tasks", TASKS_PAGE_SIZE)
+	}
+	return result.Entities, nil
+}
+
+// fetchCompletedTasks loads the tasks completed outside the query window by
+// uuid, so that tasks failing long after their creation are counted too
+func (e *TasksExporter) fetchCompletedTasks(uuids []string) []map[string]interface{} {
+	tasks := []map[string]interface{}{}
+	for _, uuid := range uuids {
+		resp, err := e.api.makeV2Request("GET", "/tasks/"+uuid, nil)
+		if err != nil {
+			e.api.Logger().Errorf("Task %s discovery failed: %v", uuid, err)
+			continue
+		}
+		var task map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&task)
+		resp.Body.Close()
+		if err != nil {
+			e.api.Logger().Errorf("Failed to decode task %s: %v", uuid, err)
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// Describe - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *TasksExporter) Describe(ch chan<- *prometheus.Desc) {
+	now := e.now()
+
+	tasks, err := e.fetchTasks(fmt.Sprintf(TASKS_LIST_REQUEST_BODY, now.Add(-TASKS_WINDOW).UnixMicro(), TASKS_PAGE_SIZE))
+	if err != nil {
+		e.tasks = nil
+		e.api.Logger().Error("Task discovery failed")
+		return
+	}
+	running, err := e.fetchTasks(fmt.Sprintf(TASKS_RUNNING_REQUEST_BODY, TASKS_PAGE_SIZE))
+	if err != nil {
+		e.tasks = nil
+		e.api.Logger().Error("Running task discovery failed")
+		return
+	}
+	e.tasks = tasks
+	e.running = running
+	state := getTasksState(e.api.url)
+	completed := e.fetchCompletedTasks(state.completedOutsideWindow(tasks, running))
+	e.failed = state.recordFailed(append(completed, tasks...), now, TASKS_WINDOW)
+
+	e.metrics[METRIC_TASKS_COUNT] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_TASKS_COUNT,
+		Help:      "Count tasks created within the query window by operation type and status"}, []string{"operation_type", "status"})
+	e.metrics[METRIC_TASKS_COUNT].Describe(ch)
+
+	e.metrics[METRIC_TASKS_OLDEST_AGE] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      METRIC_TASKS_OLDEST_AGE,
+		Help:      "Age of the oldest running task"}, []string{})
+	e.metrics[METRIC_TASKS_OLDEST_AGE].Describe(ch)
+
+	ch <- descTasksFailed
+}
+
+// Collect - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *TasksExporter) Collect(ch chan<- prometheus.Metric) {
+	if e.tasks == nil {
+		return
+	}
+	now := e.now()
+
+	for _, task := range e.tasks {
+		operation, _ := task["operation_type"].(string)
+		status, _ := task["progress_status"].(string)
+		e.metrics[METRIC_TASKS_COUNT].WithLabelValues(operation, status).Inc()
+	}
+	e.metrics[METRIC_TASKS_COUNT].Collect(ch)
+
+	var oldest float64 = 0
+	for _, task := range e.running {
+		if task["progress_status"] == TASK_STATUS_RUNNING {
+			started := task["start_time_usecs"]
+			if started == nil {
+				started = task["create_time_usecs"]
+			}
+			age := now.Sub(usecsToTime(started)).Seconds()
+			if age > oldest {
+				oldest = age
+			}
+		}
+	}
+
+	g := e.metrics[METRIC_TASKS_OLDEST_AGE].WithLabelValues()
+	g.Set(oldest)
+	g.Collect(ch)
+
+	for operation, count := range e.failed {
+		ch <- prometheus.MustNewConstMetric(descTasksFailed, prometheus.CounterValue, float64(count), operation)
+	}
+	e.api.Logger().Debugf("Task data collected for %d tasks", len(e.tasks))
+}
+
+// NewTasksCollector
+func NewTasksCollector(_api *Nutanix) *TasksExporter {
+	return &TasksExporter{
+		now: time.Now,
+		nutanixExporter: &nutanixExporter{
+			api:       *_api,
+			metrics:   make(map[string]*prometheus.GaugeVec),
+			namespace: "nutanix_tasks",
+		},
+	}
+}
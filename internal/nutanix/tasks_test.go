@@ -0,0 +1,116 @@
+package nutanix
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTasksRecordFailed(t *testing.T) {
+	// Reset global state
+	tasksMu.Lock()
+	tasksBySection = make(map[string]*tasksState)
+	tasksMu.Unlock()
+
+	now := time.Now()
+	created := float64(now.Add(-10 * time.Minute).UnixMicro())
+	tasks := []map[string]interface{}{
+		{"uuid": "t1", "operation_type": "VmMigrate", "progress_status": "Failed", "create_time_usecs": created},
+		{"uuid": "t2", "operation_type": "VmMigrate", "progress_status": "Succeeded", "create_time_usecs": created},
+		{"uuid": "t3", "operation_type": "Upgrade", "progress_status": "Failed", "create_time_usecs": created},
+	}
+
+	s := getTasksState("test-section")
+	failed := s.recordFailed(tasks, now, TASKS_WINDOW)
+	assert.Equal(t, map[string]uint64{"VmMigrate": 1, "Upgrade": 1}, failed)
+
+	// The same failed tasks are returned by the next scrape and must not be counted again
+	failed = s.recordFailed(tasks, now, TASKS_WINDOW)
+	assert.Equal(t, map[string]uint64{"VmMigrate": 1, "Upgrade": 1}, failed)
+
+	// Failed tasks created before the window but still returned are not
+	// counted again
+	failed = s.recordFailed(tasks, now.Add(3*TASKS_WINDOW), TASKS_WINDOW)
+	assert.Equal(t, map[string]uint64{"VmMigrate": 1, "Upgrade": 1}, failed)
+
+	// Tasks not returned for longer than the window are forgotten, the counters remain
+	failed = s.recordFailed(nil, now.Add(5*TASKS_WINDOW), TASKS_WINDOW)
+	assert.Empty(t, s.seenFailed)
+	assert.Equal(t, map[string]uint64{"VmMigrate": 1, "Upgrade": 1}, failed)
+
+	// Other sections are independent
+	assert.Empty(t, getTasksState("other-section").failed)
+}
+
+func TestTasksOldestRunningOutsideWindow(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	stuck := now.Add(-3 * time.Hour).UnixMicro()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "cut_off_time_usecs") {
+			// The stuck task was created before the window
+			w.Write([]byte(`{"entities": [{"uuid": "t1", "operation_type": "VmMigrate", "progress_status": "Succeeded"}]}`))
+			return
+		}
+		w.Write([]byte(`{"entities": [{"uuid": "t2", "operation_type": "Upgrade", "progress_status": "Running", "start_time_usecs": ` +
+			strconv.FormatInt(stuck, 10) + `}]}`))
+	}))
+	defer server.Close()
+
+	e := NewTasksCollector(NewNutanix(server.URL, "user", "pass", 1))
+	e.now = func() time.Time { return now }
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP nutanix_tasks_oldest_running_age_seconds Age of the oldest running task
+# TYPE nutanix_tasks_oldest_running_age_seconds gauge
+nutanix_tasks_oldest_running_age_seconds 10800
+`), "nutanix_tasks_oldest_running_age_seconds"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.metrics[METRIC_TASKS_COUNT].WithLabelValues("VmMigrate", "Succeeded")))
+}
+
+func TestTasksFailedAfterWindow(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	var upgradeDone atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			assert.Equal(t, "/PrismGateway/services/rest/v2.0/tasks/t1/", r.URL.Path)
+			w.Write([]byte(`{"uuid": "t1", "operation_type": "Upgrade", "progress_status": "Failed"}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		// The upgrade was created before the window
+		if strings.Contains(string(body), "cut_off_time_usecs") || upgradeDone.Load() {
+			w.Write([]byte(`{"entities": []}`))
+			return
+		}
+		w.Write([]byte(`{"entities": [{"uuid": "t1", "operation_type": "Upgrade", "progress_status": "Running"}]}`))
+	}))
+	defer server.Close()
+	api := NewNutanix(server.URL, "user", "pass", 1)
+
+	scrape := func() *TasksExporter {
+		e := NewTasksCollector(api)
+		e.now = func() time.Time { return now }
+		testutil.CollectAndCount(e)
+		return e
+	}
+	assert.Empty(t, scrape().failed)
+
+	// The upgrade failed since the last scrape
+	upgradeDone.Store(true)
+	assert.Equal(t, map[string]uint64{"Upgrade": 1}, scrape().failed)
+	// and is counted once
+	assert.Equal(t, map[string]uint64{"Upgrade": 1}, scrape().failed)
+}
@@ -0,0 +1,251 @@
+package nutanix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	TIME_SERIES_DEFAULT_WINDOW   = time.Minute
+	TIME_SERIES_DEFAULT_INTERVAL = 30 * time.Second
+	// Prism has no stats query of several entities, every entity costs one
+	// request per scrape
+	TIME_SERIES_DEFAULT_MAX_ENTITIES = 500
+	TIME_SERIES_SUFFIX_MAX           = "_max"
+	TIME_SERIES_SUFFIX_AVG           = "_avg"
+)
+
+// timeSeriesPaths lists the collectors supporting time series queries and
+// the v2 path of their entities
+var timeSeriesPaths = map[string]string{
+	"vms":   "/vms",
+	"hosts": "/hosts",
+}
+
+// TimeSeriesConfig selects stats queried from the per entity stats endpoints
+// instead of the snapshot embedded in the list responses
+type TimeSeriesConfig struct {
+	Metrics []string `yaml:"metrics"`
+	// Time range queried, should match the scrape interval
+	Window time.Duration `yaml:"window"`
+	// Sampling interval requested from Prism
+	Interval time.Duration `yaml:"interval"`
+	// Entities queried per scrape, the others are skipped
+	MaxEntities int `yaml:"max_entities"`
+}
+
+// Validate checks the time series config of the collector
+func (c *TimeSeriesConfig) Validate(collector string) error {
+	if _, ok := timeSeriesPaths[collector]; !ok {
+		return fmt.Errorf("time series are not supported for collector %s", collector)
+	}
+	if c.Window < 0 || c.Interval < 0 || c.MaxEntities < 0 {
+		return fmt.Errorf("time series window, interval and max_entities must not be negative")
+	}
+	for _, metric := range c.Metrics {
+		if metricCatalog[metric].counter {
+			return fmt.Errorf("time series of counter %s are not supported", metric)
+		}
+	}
+	return nil
+}
+
+// timeSeriesValue is the aggregate of the samples of a stat within the window
+type timeSeriesValue struct {
+	max float64
+	avg float64
+}
+
+type timeSeriesResponse struct {
+	StatsSpecificResponses []struct {
+		Successful       bool      `json:"successful"`
+		Message          string    `json:"message"`
+		Metric           string    `json:"metric"`
+		StartTimeInUsecs int64     `json:"start_time_in_usecs"`
+		IntervalInSecs   int64     `json:"interval_in_secs"`
+		Values           []float64 `json:"values"`
+	} `json:"stats_specific_responses"`
+}
+
+// statSeries holds the samples of a stat returned by a stats endpoint,
+// samples of -1 are not available
+type statSeries struct {
+	start    time.Time
+	interval time.Duration
+	values   []float64
+}
+
+// entityUUIDs returns the uuids of the entities
+func entityUUIDs(entities []interface{}) []string {
+	uuids := []string{}
+	for _, entRaw := range entities {
+		if uuid, ok := entRaw.(map[string]interface{})["uuid"].(string); ok {
+			uuids = append(uuids, uuid)
+		}
+	}
+	return uuids
+}
+
+// fetchTimeSeries queries the configured stats of the entities. The stats
+// endpoints take one entity, so all metrics of an entity are queried in one
+// request, the requests are bounded by the max parallel requests of the
+// section and the entities by the max entities of the config.
+func (e *nutanixExporter) fetchTimeSeries(collector string, uuids []string) {
+	e.timeSeries = make(map[string]map[string]timeSeriesValue)
+	if e.time_series == nil || len(e.time_series.Metrics) == 0 {
+		return
+	}
+
+	maxEntities := e.time_series.MaxEntities
+	if maxEntities == 0 {
+		maxEntities = TIME_SERIES_DEFAULT_MAX_ENTITIES
+	}
+	if len(uuids) > maxEntities {
+		e.api.Logger().Warnf("Time series of %s limited to %d of %d entities, narrow the entities with filters or raise max_entities", collector, maxEntities, len(uuids))
+		// The same entities are queried by every scrape
+		uuids = append([]string{}, uuids...)
+		sort.Strings(uuids)
+		uuids = uuids[:maxEntities]
+	}
+
+	window, interval := e.time_series.Window, e.time_series.Interval
+	if window == 0 {
+		window = TIME_SERIES_DEFAULT_WINDOW
+	}
+	if interval == 0 {
+		interval = TIME_SERIES_DEFAULT_INTERVAL
+	}
+	end := time.Now()
+	params := url.Values{}
+	params.Set("metrics", strings.Join(e.time_series.Metrics, ","))
+	params.Set("start_time_in_usecs", strconv.FormatInt(end.Add(-window).UnixMicro(), 10))
+	params.Set("end_time_in_usecs", strconv.FormatInt(end.UnixMicro(), 10))
+	params.Set("interval_in_secs", strconv.Itoa(int(interval.Seconds())))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, e.api.maxParallelRequests)
+	for _, uuid := range uuids {
+		wg.Add(1)
+		go func(uuid string) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire a token
+			defer func() { <-semaphore }() // Release the token
+
+			values, err := e.api.fetchEntityTimeSeries(timeSeriesPaths[collector], uuid, params)
+			if err != nil {
+				e.api.Logger().Errorf("Time series query for %s %s failed: %v", collector, uuid, err)
+				return
+			}
+			mu.Lock()
+			e.timeSeries[uuid] = values
+			mu.Unlock()
+		}(uuid)
+	}
+	wg.Wait()
+}
+
+// fetchStats queries a stats endpoint, e.g. action = "/hosts/<uuid>/stats"
+func (g *Nutanix) fetchStats(action string, params url.Values) (map[string]statSeries, error) {
+	resp, err := g.makeV2Request("GET", action, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var data timeSeriesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode stats: %w", err)
+	}
+
+	result := make(map[string]statSeries)
+	for _, stat := range data.StatsSpecificResponses {
+		if !stat.Successful {
+			g.Logger().Debugf("Stat %s of %s not available: %s", stat.Metric, action, stat.Message)
+			continue
+		}
+		result[stat.Metric] = statSeries{
+			start:    time.UnixMicro(stat.StartTimeInUsecs),
+			interval: time.Duration(stat.IntervalInSecs) * time.Second,
+			values:   stat.Values,
+		}
+	}
+	return result, nil
+}
+
+// fetchEntityTimeSeries queries the stats endpoint of an entity and
+// aggregates the samples per stat
+func (g *Nutanix) fetchEntityTimeSeries(path, uuid string, params url.Values) (map[string]timeSeriesValue, error) {
+	series, err := g.fetchStats(fmt.Sprintf("%s/%s/stats", path, uuid), params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]timeSeriesValue)
+	for metric, stat := range series {
+		var value timeSeriesValue
+		samples := 0
+		for _, v := range stat.values {
+			if v < 0 {
+				continue
+			}
+			if samples == 0 || v > value.max {
+				value.max = v
+			}
+			value.avg += v
+			samples++
+		}
+		if samples == 0 {
+			continue
+		}
+		value.avg /= float64(samples)
+		result[metric] = value
+	}
+	return result, nil
+}
+
+// describeTimeSeries registers the max and avg metrics of the time series stats
+func (e *nutanixExporter) describeTimeSeries(ch chan<- *prometheus.Desc, labels []string) {
+	if e.time_series == nil {
+		return
+	}
+	for _, key := range e.time_series.Metrics {
+		for suffix, help := range map[string]string{
+			TIME_SERIES_SUFFIX_MAX: "maximum",
+			TIME_SERIES_SUFFIX_AVG: "average",
+		} {
+			name := e.metricName(key) + suffix
+			e.metrics[name] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Namespace: e.namespace,
+				Name:      name,
+				Help:      fmt.Sprintf("%s, %s over the time series window", e.metricHelp(key), help)}, labels)
+			e.metrics[name].Describe(ch)
+		}
+	}
+}
+
+// collectTimeSeries publishes the max and avg of the time series stats of an entity
+func (e *nutanixExporter) collectTimeSeries(ch chan<- prometheus.Metric, uuid string, labelValues ...string) {
+	for key, value := range e.timeSeries[uuid] {
+		for suffix, v := range map[string]float64{
+			TIME_SERIES_SUFFIX_MAX: value.max,
+			TIME_SERIES_SUFFIX_AVG: value.avg,
+		} {
+			vec, ok := e.metrics[e.metricName(key)+suffix]
+			if !ok {
+				continue
+			}
+			g := vec.WithLabelValues(labelValues...)
+			g.Set(e.metricValue(key, v))
+			g.Collect(ch)
+		}
+	}
+}
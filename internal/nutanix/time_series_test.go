@@ -0,0 +1,94 @@
+package nutanix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchTimeSeries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/PrismGateway/services/rest/v2.0/hosts/h1/stats/", r.URL.Path)
+		assert.Equal(t, "hypervisor_cpu_usage_ppm,controller_avg_io_latency_usecs", r.URL.Query().Get("metrics"))
+		assert.Equal(t, "30", r.URL.Query().Get("interval_in_secs"))
+		assert.NotEmpty(t, r.URL.Query().Get("start_time_in_usecs"))
+		w.Write([]byte(`{"stats_specific_responses": [
+			{"successful": true, "metric": "hypervisor_cpu_usage_ppm", "values": [100000, 900000, -1, 200000]},
+			{"successful": false, "message": "not available", "metric": "controller_avg_io_latency_usecs", "values": []}
+		]}`))
+	}))
+	defer server.Close()
+
+	e := &nutanixExporter{
+		api:         *NewNutanix(server.URL, "user", "pass", 2),
+		metrics:     make(map[string]*prometheus.GaugeVec),
+		namespace:   "nutanix_hosts",
+		time_series: &TimeSeriesConfig{Metrics: []string{"hypervisor_cpu_usage_ppm", "controller_avg_io_latency_usecs"}},
+	}
+	e.SetMetricNaming(METRIC_NAMING_V2)
+	e.fetchTimeSeries("hosts", []string{"h1"})
+	require.Contains(t, e.timeSeries, "h1")
+	assert.Equal(t, timeSeriesValue{max: 900000, avg: 400000}, e.timeSeries["h1"]["hypervisor_cpu_usage_ppm"])
+	assert.NotContains(t, e.timeSeries["h1"], "controller_avg_io_latency_usecs")
+
+	descs := make(chan *prometheus.Desc, 10)
+	e.describeTimeSeries(descs, []string{"uuid"})
+	close(descs)
+
+	ch := make(chan prometheus.Metric, 10)
+	e.collectTimeSeries(ch, "h1", "h1")
+	close(ch)
+	assert.Len(t, ch, 2)
+	values := map[string]float64{}
+	for name, vec := range e.metrics {
+		values[e.namespace+"_"+name] = testutil.ToFloat64(vec.WithLabelValues("h1"))
+	}
+	assert.InDelta(t, 0.9, values["nutanix_hosts_hypervisor_cpu_usage_ratio_max"], 1e-9)
+	assert.InDelta(t, 0.4, values["nutanix_hosts_hypervisor_cpu_usage_ratio_avg"], 1e-9)
+}
+
+func TestFetchTimeSeriesMaxEntities(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(`{"stats_specific_responses": [
+			{"successful": true, "metric": "hypervisor_cpu_usage_ppm", "values": [100000]}
+		]}`))
+	}))
+	defer server.Close()
+
+	e := &nutanixExporter{
+		api:         *NewNutanix(server.URL, "user", "pass", 2),
+		metrics:     make(map[string]*prometheus.GaugeVec),
+		namespace:   "nutanix_vms",
+		time_series: &TimeSeriesConfig{Metrics: []string{"hypervisor_cpu_usage_ppm"}, MaxEntities: 2},
+	}
+	uuids := []string{"v3", "v1", "v2"}
+	e.fetchTimeSeries("vms", uuids)
+	assert.ElementsMatch(t, []string{
+		"/PrismGateway/services/rest/v2.0/vms/v1/stats/",
+		"/PrismGateway/services/rest/v2.0/vms/v2/stats/",
+	}, paths)
+	assert.Len(t, e.timeSeries, 2)
+	assert.NotContains(t, e.timeSeries, "v3")
+	assert.Equal(t, []string{"v3", "v1", "v2"}, uuids)
+}
+
+func TestTimeSeriesConfigValidate(t *testing.T) {
+	assert.NoError(t, (&TimeSeriesConfig{Metrics: []string{"hypervisor_cpu_usage_ppm"}}).Validate("vms"))
+	err := (&TimeSeriesConfig{}).Validate("storage_containers")
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "not supported"))
+	assert.Error(t, (&TimeSeriesConfig{Metrics: []string{"hypervisor_num_received_bytes"}}).Validate("vms"))
+	assert.Error(t, (&TimeSeriesConfig{Metrics: []string{"hypervisor_cpu_usage_ppm"}, MaxEntities: -1}).Validate("vms"))
+}
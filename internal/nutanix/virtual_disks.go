@@ -1,11 +1,11 @@
 package nutanix
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -22,7 +22,7 @@ func (e *VirtualDisksExporter) Describe(ch chan<- *prometheus.Desc) {
 	entities, err := e.api.fetchAllPages("/virtual_disks", nil)
 	if err != nil {
 		e.result = nil
-		log.Error("Virtual disk discovery failed")
+		e.api.Logger().Error("Virtual disk discovery failed")
 		return
 	}
 
@@ -42,32 +42,20 @@ func (e *VirtualDisksExporter) Describe(ch chan<- *prometheus.Desc) {
 
 		// Publish host properties as separate record
 		key := KEY_HOST_PROPERTIES
-		e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: e.namespace,
-			Name:      key, Help: "..."}, e.properties)
-		e.metrics[key].Describe(ch)
+		e.describeProperties(ch, key, e.properties)
 
 		if stats != nil {
 			e.addCalculatedStats(stats)
 			for key := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
-				key = e.normalizeKey(key)
-
-				e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-					Namespace: e.namespace,
-					Name:      key, Help: "..."}, []string{"uuid", "attached_vm_uuid"})
-
-				e.metrics[key].Describe(ch)
+				e.describeStat(ch, key, []string{"uuid", "attached_vm_uuid"})
 			}
 		}
-		for _, key := range e.fields {
-			e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-				Namespace: e.namespace,
-				Name:      key, Help: "..."}, []string{"uuid", "attached_vm_uuid"})
-			e.metrics[key].Describe(ch)
+		for _, key := range e.allFields() {
+			e.describeStat(ch, key, []string{"uuid", "attached_vm_uuid"})
 		}
 
 	}
@@ -135,14 +123,12 @@ func (e *VirtualDisksExporter) Collect(ch chan<- prometheus.Metric) {
 			default:
 				obj := ent[property]
 				if obj != nil {
-					val = ent[property].(string)
+					val = fmt.Sprintf("%v", obj)
 				}
 			}
 			property_values = append(property_values, val)
 		}
-		g := e.metrics[key].WithLabelValues(property_values...)
-		g.Set(1)
-		g.Collect(ch)
+		e.collectProperties(ch, key, property_values)
 
 		val := ent["attached_vm_uuid"]
 		var vmUUID string = ""
@@ -152,7 +138,7 @@ func (e *VirtualDisksExporter) Collect(ch chan<- prometheus.Metric) {
 
 		if stats != nil {
 			for key, value := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
@@ -165,19 +151,14 @@ func (e *VirtualDisksExporter) Collect(ch chan<- prometheus.Metric) {
 				if strings.Contains(key, "histogram") {
 					continue
 				}
-				key = e.normalizeKey(key)
-				g := e.metrics[key].WithLabelValues(ent["uuid"].(string), vmUUID)
-				g.Set(val)
-				g.Collect(ch)
+				e.collectStat(ch, key, val, ent["uuid"].(string), vmUUID)
 			}
 
 		}
-		for _, key := range e.fields {
-			g := e.metrics[key].WithLabelValues(ent["uuid"].(string), vmUUID)
-			g.Set(e.valueToFloat64(ent[key]))
-			g.Collect(ch)
+		for _, key := range e.allFields() {
+			e.collectStat(ch, key, e.valueToFloat64(ent[key]), ent["uuid"].(string), vmUUID)
 		}
-		log.Debugf("Virtual Disk data collected for virtual disk: UUID=%s", ent["uuid"])
+		e.api.Logger().Debugf("Virtual Disk data collected for virtual disk: UUID=%s", ent["uuid"])
 	}
 }
 
@@ -190,6 +171,8 @@ func NewVirtualDisksCollector(_api *Nutanix) *VirtualDisksExporter {
 			namespace:  "nutanix_vdisks",
 			fields:     []string{"disk_capacity_in_bytes"},
 			properties: []string{"uuid", "attached_vm_uuid", "attached_vmname", "storage_container_uuid", "cluster_uuid", "disk_address", "disk_capacity_in_mb"},
+			// The capacity is a field
+			volatileProperties: map[string]bool{"disk_capacity_in_mb": true},
 			filter_stats: map[string]bool{
 				"controller_total_read_io_size_kbytes":  true,
 				"controller_total_io_size_kbytes":       true,
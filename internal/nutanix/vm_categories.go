@@ -0,0 +1,235 @@
+package nutanix
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	KEY_VM_CATEGORIES_INFO      = "info"
+	VM_CATEGORIES_PAGE_SIZE     = 500
+	VM_CATEGORIES_DEFAULT_TTL   = 10 * time.Minute
+	VM_CATEGORIES_LIST_BODY     = `{"kind": "vm", "length": %d, "offset": %d}`
+	VM_CATEGORY_LABEL_PREFIX    = "category_"
+	VM_CATEGORY_PROJECT         = "project"
+	VM_CATEGORY_VALUE_SEPARATOR = ","
+)
+
+// vmCategoryInfo holds the Prism Central categories and project of a VM
+type vmCategoryInfo struct {
+	categories map[string]string // category -> value(s)
+	project    string
+}
+
+// vmCategoriesEntry is the cached result of a Prism Central lookup
+type vmCategoriesEntry struct {
+	mu      sync.Mutex
+	fetched time.Time
+	vms     map[string]vmCategoryInfo // vm uuid -> categories
+}
+
+// vmCategoryLabelChars matches the characters not allowed in a label name
+var vmCategoryLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+var (
+	vmCategoriesMu    sync.Mutex
+	vmCategoriesCache = map[string]*vmCategoriesEntry{}
+)
+
+// VmCategories resolves VM categories and project ownership from the Prism
+// Central v3 API. Results are cached per Prism Central and cluster across
+// scrapes.
+type VmCategories struct {
+	api     *Nutanix
+	cluster *Nutanix
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// NewVmCategories - pc is the client of the Prism Central managing the
+// cluster, only the VMs of the cluster of the cluster client are kept
+func NewVmCategories(pc *Nutanix, cluster *Nutanix, ttl time.Duration) *VmCategories {
+	if ttl <= 0 {
+		ttl = VM_CATEGORIES_DEFAULT_TTL
+	}
+	return &VmCategories{api: pc, cluster: cluster, ttl: ttl, now: time.Now}
+}
+
+func (c *VmCategories) entry() *vmCategoriesEntry {
+	vmCategoriesMu.Lock()
+	defer vmCategoriesMu.Unlock()
+	key := c.api.url + "|" + c.api.username + "|" + c.cluster.url
+	entry, ok := vmCategoriesCache[key]
+	if !ok {
+		entry = &vmCategoriesEntry{}
+		vmCategoriesCache[key] = entry
+	}
+	return entry
+}
+
+// Get returns the categories per VM uuid, refreshed when older than the TTL.
+// A failed refresh keeps serving the previous result.
+func (c *VmCategories) Get() map[string]vmCategoryInfo {
+	entry := c.entry()
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.vms != nil && c.now().Sub(entry.fetched) < c.ttl {
+		return entry.vms
+	}
+
+	vms, err := c.fetch()
+	if err != nil {
+		c.api.Logger().Errorf("VM categories discovery failed: %v", err)
+		return entry.vms
+	}
+	entry.vms = vms
+	entry.fetched = c.now()
+	c.api.Logger().Debugf("VM categories loaded for %d VMs", len(vms))
+	return entry.vms
+}
+
+func (c *VmCategories) fetch() (map[string]vmCategoryInfo, error) {
+	// Prism Central lists the VMs of all its clusters
+	clusterUUID, err := c.cluster.GetClusterUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	vms := make(map[string]vmCategoryInfo)
+	offset := 0
+	for {
+		body := fmt.Sprintf(VM_CATEGORIES_LIST_BODY, VM_CATEGORIES_PAGE_SIZE, offset)
+		resp, err := c.api.makeV3PostRequest("/vms/list", body)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Metadata struct {
+				TotalMatches int `json:"total_matches"`
+			} `json:"metadata"`
+			Entities []struct {
+				Status struct {
+					ClusterReference struct {
+						UUID string `json:"uuid"`
+					} `json:"cluster_reference"`
+				} `json:"status"`
+				Metadata struct {
+					UUID              string              `json:"uuid"`
+					Categories        map[string]string   `json:"categories"`
+					CategoriesMapping map[string][]string `json:"categories_mapping"`
+					ProjectReference  struct {
+						Name string `json:"name"`
+					} `json:"project_reference"`
+				} `json:"metadata"`
+			} `json:"entities"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ent := range result.Entities {
+			if ent.Status.ClusterReference.UUID != clusterUUID {
+				continue
+			}
+			info := vmCategoryInfo{
+				categories: make(map[string]string),
+				project:    ent.Metadata.ProjectReference.Name,
+			}
+			for category, value := range ent.Metadata.Categories {
+				info.categories[category] = value
+			}
+			// categories_mapping holds all values of multi-valued categories
+			for category, values := range ent.Metadata.CategoriesMapping {
+				sorted := append([]string{}, values...)
+				sort.Strings(sorted)
+				info.categories[category] = strings.Join(sorted, VM_CATEGORY_VALUE_SEPARATOR)
+			}
+			vms[ent.Metadata.UUID] = info
+		}
+
+		offset += len(result.Entities)
+		if len(result.Entities) == 0 || offset >= result.Metadata.TotalMatches {
+			break
+		}
+	}
+	return vms, nil
+}
+
+// categoryLabelName returns the label name used for a category on VM metrics.
+// Characters not allowed in label names are replaced by underscores.
+func categoryLabelName(category string) string {
+	return VM_CATEGORY_LABEL_PREFIX + vmCategoryLabelChars.ReplaceAllString(strings.ToLower(category), "_")
+}
+
+// ValidateCategoryLabels checks that the categories published as labels map
+// to distinct label names
+func ValidateCategoryLabels(categories []string) error {
+	seen := make(map[string]string, len(categories))
+	for _, category := range categories {
+		name := categoryLabelName(category)
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("categories %q and %q both map to label %s", other, category, name)
+		}
+		seen[name] = category
+	}
+	return nil
+}
+
+// VmCategoriesExporter
+type VmCategoriesExporter struct {
+	*nutanixExporter
+	categories *VmCategories
+	vms        map[string]vmCategoryInfo
+}
+
+// Describe - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *VmCategoriesExporter) Describe(ch chan<- *prometheus.Desc) {
+	e.vms = e.categories.Get()
+
+	e.metrics[KEY_VM_CATEGORIES_INFO] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      KEY_VM_CATEGORIES_INFO,
+		Help:      "Prism Central category value and project of a VM"}, []string{"uuid", "category", "value"})
+	e.metrics[KEY_VM_CATEGORIES_INFO].Describe(ch)
+}
+
+// Collect - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *VmCategoriesExporter) Collect(ch chan<- prometheus.Metric) {
+	for uuid, info := range e.vms {
+		for category, value := range info.categories {
+			g := e.metrics[KEY_VM_CATEGORIES_INFO].WithLabelValues(uuid, category, value)
+			g.Set(1)
+			g.Collect(ch)
+		}
+		if len(info.project) > 0 {
+			g := e.metrics[KEY_VM_CATEGORIES_INFO].WithLabelValues(uuid, VM_CATEGORY_PROJECT, info.project)
+			g.Set(1)
+			g.Collect(ch)
+		}
+	}
+}
+
+// NewVmCategoriesCollector
+func NewVmCategoriesCollector(_api *Nutanix, categories *VmCategories) *VmCategoriesExporter {
+	return &VmCategoriesExporter{
+		categories: categories,
+		nutanixExporter: &nutanixExporter{
+			api:       *_api,
+			metrics:   make(map[string]*prometheus.GaugeVec),
+			namespace: "nutanix_vm_categories",
+		},
+	}
+}
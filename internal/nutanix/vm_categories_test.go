@@ -0,0 +1,70 @@
+package nutanix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVmCategoriesCache(t *testing.T) {
+	// Reset global state
+	vmCategoriesMu.Lock()
+	vmCategoriesCache = make(map[string]*vmCategoriesEntry)
+	vmCategoriesMu.Unlock()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api/nutanix/v3/vms/list", r.URL.Path)
+		w.Write([]byte(`{"metadata": {"total_matches": 2}, "entities": [{
+			"status": {"cluster_reference": {"uuid": "cluster1"}},
+			"metadata": {
+			"uuid": "vm1",
+			"categories": {"AppType": "Oracle"},
+			"categories_mapping": {"Environment": ["Prod", "DR"]},
+			"project_reference": {"name": "databases"}}}, {
+			"status": {"cluster_reference": {"uuid": "cluster2"}},
+			"metadata": {"uuid": "vm2", "categories": {"AppType": "Web"}}}]}`))
+	}))
+	defer server.Close()
+	cluster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"uuid": "cluster1"}`))
+	}))
+	defer cluster.Close()
+
+	now := time.Now()
+	categories := NewVmCategories(NewNutanix(server.URL, "user", "pass", 5), NewNutanix(cluster.URL, "user", "pass", 5), time.Minute)
+	categories.now = func() time.Time { return now }
+
+	vms := categories.Get()
+	assert.Equal(t, "Oracle", vms["vm1"].categories["AppType"])
+	assert.Equal(t, "DR,Prod", vms["vm1"].categories["Environment"])
+	assert.Equal(t, "databases", vms["vm1"].project)
+	// VMs of other clusters managed by the Prism Central are dropped
+	assert.NotContains(t, vms, "vm2")
+
+	// Cached within the TTL
+	categories.Get()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// Refreshed after the TTL
+	now = now.Add(2 * time.Minute)
+	categories.Get()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestCategoryLabelName(t *testing.T) {
+	assert.Equal(t, "category_apptype", categoryLabelName("AppType"))
+	assert.Equal(t, "category_app_type", categoryLabelName("App Type"))
+	assert.Equal(t, "category_1st_tier_owner", categoryLabelName("1st-Tier/Owner"))
+	assert.True(t, model.LabelName(categoryLabelName("Kosten.Stelle ä")).IsValid())
+
+	assert.NoError(t, ValidateCategoryLabels([]string{"AppType", "Environment"}))
+	assert.Error(t, ValidateCategoryLabels([]string{"App Type", "app_type"}))
+}
@@ -6,7 +6,6 @@ import (
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
 const KEY_VM_NIC_PROPERTIES = "properties"
@@ -23,20 +22,20 @@ func (e *VMNicsExporter) Describe(ch chan<- *prometheus.Desc) {
 
 	// Construct the NIC endpoint using the single vm UUID
 	nicEndpoint := fmt.Sprintf("/vms/%s/virtual_nics", uuid)
-	log.Debug("VM Nic Endpoint: " + nicEndpoint)
+	e.api.Logger().Debug("VM Nic Endpoint: " + nicEndpoint)
 
 	// Make the API request to fetch vm NICs information (no paging)
 	resp, err := e.api.makeV1Request("GET", nicEndpoint, nil)
 	if err != nil {
 		e.result = nil
-		log.Error("VM nic discovery failed")
+		e.api.Logger().Error("VM nic discovery failed")
 		return
 	}
 
 	var entities []map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&entities); err != nil {
 		e.result = nil
-		log.Error("Failed to decode VM NICs response")
+		e.api.Logger().Error("Failed to decode VM NICs response")
 		return
 	}
 
@@ -63,23 +62,19 @@ func (e *VMNicsExporter) Describe(ch chan<- *prometheus.Desc) {
 
 		// Publish vm properties as separate record
 		key := KEY_VM_NIC_PROPERTIES
-		e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: e.namespace,
-			Name:      key, Help: "..."}, e.properties)
-		e.metrics[key].Describe(ch)
+		e.describeProperties(ch, key, e.properties)
+		e.describeIPAddresses(ch, []string{"uuid", "vmUuid"})
+		for _, key := range e.allFields() {
+			e.describeStat(ch, key, []string{"uuid", "vmUuid"})
+		}
 
 		if stats != nil {
 			for key := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
-				key = e.normalizeKey(key)
-				e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-					Namespace: e.namespace,
-					Name:      key, Help: "..."}, []string{"uuid", "vmUuid"})
-
-				e.metrics[key].Describe(ch)
+				e.describeStat(ch, key, []string{"uuid", "vmUuid"})
 			}
 		}
 	}
@@ -133,13 +128,12 @@ func (e *VMNicsExporter) Collect(ch chan<- prometheus.Metric) {
 			}
 			property_values = append(property_values, val)
 		}
-		g := e.metrics[key].WithLabelValues(property_values...)
-		g.Set(1)
-		g.Collect(ch)
+		e.collectProperties(ch, key, property_values)
+		e.collectIPAddresses(ch, ent["ipv4Addresses"], ent["uuid"].(string), ent["vmUuid"].(string))
 
 		if stats != nil {
 			for key, value := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
@@ -148,18 +142,13 @@ func (e *VMNicsExporter) Collect(ch chan<- prometheus.Metric) {
 				if val == -1 {
 					continue
 				}
-				key = e.normalizeKey(key)
-				g := e.metrics[key].WithLabelValues(ent["uuid"].(string), ent["vmUuid"].(string))
-				g.Set(val)
-				g.Collect(ch)
+				e.collectStat(ch, key, val, ent["uuid"].(string), ent["vmUuid"].(string))
 			}
 		}
-		for _, key := range e.fields {
-			g := e.metrics[key].WithLabelValues(ent["uuid"].(string), ent["vmUuid"].(string))
-			g.Set(e.valueToFloat64(ent[key]))
-			g.Collect(ch)
+		for _, key := range e.allFields() {
+			e.collectStat(ch, key, e.valueToFloat64(ent[key]), ent["uuid"].(string), ent["vmUuid"].(string))
 		}
-		log.Debugf("VMs NIC data collected for VM=%s VM_UUID=%s", e.VMName, e.VMUUID)
+		e.api.Logger().Debugf("VMs NIC data collected for VM=%s VM_UUID=%s", e.VMName, e.VMUUID)
 	}
 }
 
@@ -169,10 +158,12 @@ func NewVMsNetworkCollector(_api *Nutanix, vmname string, vmuuid string) *VMNics
 		VMName: vmname,
 		VMUUID: vmuuid,
 		nutanixExporter: &nutanixExporter{
-			api:        *_api,
-			metrics:    make(map[string]*prometheus.GaugeVec),
-			namespace:  "nutanix_vmnics",
-			properties: []string{"vmUuid", "uuid", "vmName", "macAddress", "ipv4Addresses", "name", "mtuInBytes"},
+			api:                *_api,
+			metrics:            make(map[string]*prometheus.GaugeVec),
+			namespace:          "nutanix_vmnics",
+			properties:         []string{"vmUuid", "uuid", "vmName", "macAddress", "ipv4Addresses", "name", "mtuInBytes"},
+			volatileProperties: map[string]bool{"ipv4Addresses": true, "mtuInBytes": true},
+			volatileFields:     []string{"mtuInBytes"},
 			filter_stats: map[string]bool{
 				"network.received_bytes":         true,
 				"network.received_pkts":          true,
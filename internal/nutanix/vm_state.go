@@ -0,0 +1,192 @@
+package nutanix
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	METRIC_VM_POWER_STATE      = "power_state"
+	METRIC_VM_NGT_INSTALLED    = "ngt_installed"
+	METRIC_VM_NGT_ENABLED      = "ngt_enabled"
+	METRIC_VM_NGT_MOUNTED      = "ngt_tools_mounted"
+	METRIC_VM_NGT_COMMUNICATES = "ngt_communication_link_active"
+	METRIC_VM_HA_PRIORITY      = "ha_priority"
+	METRIC_VM_AGENT            = "agent_vm"
+	METRIC_VM_AFFINITY         = "host_affinity_pinned"
+)
+
+// vmDetailMetrics are published from the v2 VM entities, which need a second
+// fetch of all VMs. They are only published when enabled in the stats.
+var vmDetailMetrics = []string{METRIC_VM_HA_PRIORITY, METRIC_VM_AGENT, METRIC_VM_AFFINITY}
+
+// vmPowerStates lists the known power states of a VM
+var vmPowerStates = []string{"on", "off", "paused", "suspended", "unknown"}
+
+// vmMigrationState tracks the host of each powered on VM across scrapes to
+// count live migrations per section.
+// Collectors are created per scrape, so the state has to outlive them.
+type vmMigrationState struct {
+	mu         sync.Mutex
+	hosts      map[string]string // vm uuid -> host uuid of the last scrape
+	migrations map[string]uint64 // vm uuid -> migrations since exporter start
+}
+
+var (
+	vmMigrationsMu        sync.Mutex
+	vmMigrationsBySection = map[string]*vmMigrationState{}
+)
+
+func getVmMigrationState(section string) *vmMigrationState {
+	vmMigrationsMu.Lock()
+	defer vmMigrationsMu.Unlock()
+	s, ok := vmMigrationsBySection[section]
+	if !ok {
+		s = &vmMigrationState{
+			hosts:      make(map[string]string),
+			migrations: make(map[string]uint64),
+		}
+		vmMigrationsBySection[section] = s
+	}
+	return s
+}
+
+// record counts a migration for every powered on VM whose host changed since
+// the last scrape. VMs no longer present are forgotten. Returns a copy of the
+// migration counters.
+func (s *vmMigrationState) record(entities []interface{}) map[string]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hosts := make(map[string]string, len(entities))
+	migrations := make(map[string]uint64, len(entities))
+	for _, entRaw := range entities {
+		ent := entRaw.(map[string]interface{})
+		uuid, ok := ent["uuid"].(string)
+		if !ok {
+			continue
+		}
+		hostUUID, _ := ent["hostUuid"].(string)
+		// A VM powered off and on again on another host is not a migration
+		if ent["powerState"] != "on" {
+			hostUUID = ""
+		}
+		if last, ok := s.hosts[uuid]; ok && len(last) > 0 && len(hostUUID) > 0 && last != hostUUID {
+			s.migrations[uuid]++
+		}
+		hosts[uuid] = hostUUID
+		migrations[uuid] = s.migrations[uuid]
+	}
+	s.hosts = hosts
+	s.migrations = migrations
+
+	result := make(map[string]uint64, len(migrations))
+	for uuid, count := range migrations {
+		result[uuid] = count
+	}
+	return result
+}
+
+var descVmLiveMigrations = prometheus.NewDesc("nutanix_vms_live_migrations_total", "Live migrations of the VM observed since exporter start", []string{"uuid"}, nil)
+
+// vmDetailsEnabled returns true if a metric of the v2 VM entities is enabled
+func (e *VmsExporter) vmDetailsEnabled() bool {
+	for _, key := range vmDetailMetrics {
+		if e.statEnabled(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchVmDetails loads HA and placement settings only available from the v2 API
+func (e *VmsExporter) fetchVmDetails() {
+	e.vmDetails = nil
+	if !e.vmDetailsEnabled() {
+		return
+	}
+	entities, err := e.api.fetchAllPages("/vms", nil)
+	if err != nil {
+		e.api.Logger().Errorf("VM details discovery failed: %v", err)
+		return
+	}
+
+	e.vmDetails = make(map[string]map[string]interface{})
+	for _, entRaw := range entities {
+		ent := entRaw.(map[string]interface{})
+		if uuid, ok := ent["uuid"].(string); ok {
+			e.vmDetails[uuid] = ent
+		}
+	}
+}
+
+// describeVmState registers the VM state metrics
+func (e *VmsExporter) describeVmState(ch chan<- *prometheus.Desc) {
+	e.describeStat(ch, METRIC_VM_POWER_STATE, append(e.labelNames(), "state"))
+
+	for _, key := range []string{METRIC_VM_NGT_INSTALLED, METRIC_VM_NGT_ENABLED, METRIC_VM_NGT_MOUNTED, METRIC_VM_NGT_COMMUNICATES} {
+		e.describeStat(ch, key, e.labelNames())
+	}
+	for _, key := range vmDetailMetrics {
+		if e.statEnabled(key) {
+			e.describeStat(ch, key, e.labelNames())
+		}
+	}
+
+	ch <- descVmLiveMigrations
+}
+
+// collectVmState publishes power state, guest tools, HA and placement of a VM
+func (e *VmsExporter) collectVmState(ch chan<- prometheus.Metric, ent map[string]interface{}, hostUUID string) {
+	uuid := ent["uuid"].(string)
+	labels := e.labelValues(uuid, hostUUID)
+
+	powerState, _ := ent["powerState"].(string)
+	e.collectStateSet(ch, METRIC_VM_POWER_STATE, labels, vmPowerStates, powerState)
+
+	boolToFloat := func(v interface{}) float64 {
+		if b, ok := v.(bool); ok && b {
+			return 1
+		}
+		return 0
+	}
+
+	values := map[string]float64{}
+	ngt, _ := ent["nutanixGuestTools"].(map[string]interface{})
+	if version, ok := ngt["installedVersion"].(string); ok && len(version) > 0 {
+		values[METRIC_VM_NGT_INSTALLED] = 1
+	} else {
+		values[METRIC_VM_NGT_INSTALLED] = 0
+	}
+	values[METRIC_VM_NGT_ENABLED] = boolToFloat(ngt["enabled"])
+	values[METRIC_VM_NGT_MOUNTED] = boolToFloat(ngt["toolsMounted"])
+	values[METRIC_VM_NGT_COMMUNICATES] = boolToFloat(ngt["communicationLinkActive"])
+
+	if details, ok := e.vmDetails[uuid]; ok {
+		detailValues := map[string]float64{METRIC_VM_AFFINITY: 0}
+		detailValues[METRIC_VM_HA_PRIORITY] = e.valueToFloat64(details["ha_priority"])
+		features, _ := details["vm_features"].(map[string]interface{})
+		detailValues[METRIC_VM_AGENT] = boolToFloat(features["AGENT_VM"])
+		if affinity, ok := details["affinity"].(map[string]interface{}); ok {
+			if hosts, ok := affinity["host_uuids"].([]interface{}); ok && len(hosts) > 0 {
+				detailValues[METRIC_VM_AFFINITY] = 1
+			}
+		}
+		for _, key := range vmDetailMetrics {
+			if e.statEnabled(key) {
+				values[key] = detailValues[key]
+			}
+		}
+	}
+
+	for key, val := range values {
+		g := e.metrics[key].WithLabelValues(labels...)
+		g.Set(val)
+		g.Collect(ch)
+	}
+
+	if count, ok := e.migrations[uuid]; ok {
+		ch <- prometheus.MustNewConstMetric(descVmLiveMigrations, prometheus.CounterValue, float64(count), uuid)
+	}
+}
@@ -0,0 +1,65 @@
+package nutanix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVmMigrationRecord(t *testing.T) {
+	// Reset global state
+	vmMigrationsMu.Lock()
+	vmMigrationsBySection = make(map[string]*vmMigrationState)
+	vmMigrationsMu.Unlock()
+
+	vm := func(uuid, host, power string) interface{} {
+		return map[string]interface{}{"uuid": uuid, "hostUuid": host, "powerState": power}
+	}
+	s := getVmMigrationState("test-section")
+
+	// First scrape only learns the placement
+	migrations := s.record([]interface{}{vm("vm1", "h1", "on"), vm("vm2", "h1", "on")})
+	assert.Equal(t, map[string]uint64{"vm1": 0, "vm2": 0}, migrations)
+
+	// vm1 moved while running
+	migrations = s.record([]interface{}{vm("vm1", "h2", "on"), vm("vm2", "h1", "off")})
+	assert.Equal(t, map[string]uint64{"vm1": 1, "vm2": 0}, migrations)
+
+	// vm2 was powered off, starting it on another host is no migration
+	migrations = s.record([]interface{}{vm("vm1", "h2", "on"), vm("vm2", "h2", "on")})
+	assert.Equal(t, map[string]uint64{"vm1": 1, "vm2": 0}, migrations)
+
+	// Deleted VMs are forgotten
+	migrations = s.record([]interface{}{vm("vm2", "h2", "on")})
+	assert.Equal(t, map[string]uint64{"vm2": 0}, migrations)
+}
+
+func TestVmDetailsFetchedOnlyWhenEnabled(t *testing.T) {
+	var v2Requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PrismGateway/services/rest/v1/vms/":
+			w.Write([]byte(`{"metadata": {"grandTotalEntities": 1, "endIndex": 1}, "entities": [{"uuid": "vm1", "hostUuid": "h1", "powerState": "on"}]}`))
+		case "/PrismGateway/services/rest/v2.0/vms/":
+			v2Requests.Add(1)
+			w.Write([]byte(`{"metadata": {"grand_total_entities": 1, "end_index": 1}, "entities": [{"uuid": "vm1", "ha_priority": 100}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	e := NewVmsCollector(NewNutanix(server.URL, "user", "pass", 1), false)
+	assert.Equal(t, 0, testutil.CollectAndCount(e, "nutanix_vms_ha_priority"))
+	assert.Equal(t, int32(0), v2Requests.Load())
+
+	e = NewVmsCollector(NewNutanix(server.URL, "user", "pass", 1), false)
+	e.Configure(&CollectorConfig{ExtraStats: []string{METRIC_VM_HA_PRIORITY}})
+	assert.Equal(t, 1, testutil.CollectAndCount(e, "nutanix_vms_ha_priority"))
+	assert.Equal(t, 0, testutil.CollectAndCount(e, "nutanix_vms_agent_vm"))
+	assert.Equal(t, int32(2), v2Requests.Load())
+}
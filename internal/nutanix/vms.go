@@ -10,16 +10,17 @@
 package nutanix
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
 const (
 	KEY_VM_PROPERTIES           = "properties"
+	KEY_VM_HOST_INFO            = "host_info"
 	METRIC_MEM_FREE_BYTES       = "memory_free_bytes"
 	METRIC_MEM_USAGE_BYTES      = "memory_usage_bytes"
 	METRIC_MEM_SWAPPED_IN_RATE  = "memory_swapped_in_rate_bps"
@@ -31,6 +32,61 @@ type VmsExporter struct {
 	*nutanixExporter
 	networkExporters map[string]*VMNicsExporter
 	collectvmnics    bool
+	nicConfig        *CollectorConfig
+	vmDetails        map[string]map[string]interface{} // vm uuid -> v2 vm entity
+	migrations       map[string]uint64                 // vm uuid -> live migrations
+	categories       *VmCategories
+	categoryLabels   []string                  // categories published as labels
+	vmCategories     map[string]vmCategoryInfo // vm uuid -> categories
+	controllerVms    []interface{}             // controller VMs, regardless of the filter
+}
+
+// WithCategoryLabels adds the allowlisted Prism Central categories as labels
+// to the per VM metrics
+func (e *VmsExporter) WithCategoryLabels(categories *VmCategories, labels []string) *VmsExporter {
+	e.categories = categories
+	e.categoryLabels = labels
+	return e
+}
+
+// ConfigureNics sets the collector config applied to the per VM NIC
+// collectors
+func (e *VmsExporter) ConfigureNics(conf *CollectorConfig) {
+	e.nicConfig = conf
+}
+
+// fetchCategories loads the categories of the VMs published as labels
+func (e *VmsExporter) fetchCategories() {
+	if e.categories != nil && len(e.categoryLabels) > 0 {
+		e.vmCategories = e.categories.Get()
+	}
+}
+
+// labelNames returns the labels of the per VM metrics. Under the v2 naming
+// the host of a VM is only published by the host_info metric, so that a live
+// migration does not create new series.
+func (e *VmsExporter) labelNames() []string {
+	names := []string{"uuid"}
+	if e.naming != METRIC_NAMING_V2 {
+		names = append(names, "host_uuid")
+	}
+	for _, category := range e.categoryLabels {
+		names = append(names, categoryLabelName(category))
+	}
+	return names
+}
+
+// labelValues returns the label values of the per VM metrics
+func (e *VmsExporter) labelValues(uuid string, hostUUID string) []string {
+	values := []string{uuid}
+	if e.naming != METRIC_NAMING_V2 {
+		values = append(values, hostUUID)
+	}
+	info := e.vmCategories[uuid]
+	for _, category := range e.categoryLabels {
+		values = append(values, info.categories[category])
+	}
+	return values
 }
 
 // Describe - Implement prometheus.Collector interface
@@ -40,9 +96,12 @@ func (e *VmsExporter) Describe(ch chan<- *prometheus.Desc) {
 	entities, err := e.api.fetchAllPagesV1("/vms", nil)
 	if err != nil {
 		e.result = nil
-		log.Error("VM discovery failed")
+		e.controllerVms = nil
+		e.api.Logger().Error("VM discovery failed")
 		return
 	}
+	e.controllerVms = controllerVms(entities)
+	entities = e.filter.filterEntities(entities, vmFilterKeys)
 
 	e.result = map[string]interface{}{"entities": entities}
 
@@ -50,6 +109,11 @@ func (e *VmsExporter) Describe(ch chan<- *prometheus.Desc) {
 		return
 	}
 
+	e.fetchCategories()
+	e.fetchVmDetails()
+	e.migrations = getVmMigrationState(e.api.url).record(entities)
+	e.describeVmState(ch)
+
 	// Publish VM properties as separate record
 	key := KEY_VM_PROPERTIES
 	property_keys := []string{}
@@ -61,10 +125,16 @@ func (e *VmsExporter) Describe(ch chan<- *prometheus.Desc) {
 		}
 		property_keys = append(property_keys, key)
 	}
-	e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: e.namespace,
-		Name:      key, Help: "..."}, property_keys)
-	e.metrics[key].Describe(ch)
+	e.describeProperties(ch, key, property_keys)
+	e.describeIPAddresses(ch, []string{"uuid"})
+	if e.naming == METRIC_NAMING_V2 {
+		e.describeStat(ch, KEY_VM_HOST_INFO, []string{"uuid", "host_uuid"})
+	}
+
+	if e.time_series != nil {
+		e.fetchTimeSeries("vms", entityUUIDs(entities))
+		e.describeTimeSeries(ch, e.labelNames())
+	}
 
 	for _, entRaw := range entities {
 		ent := entRaw.(map[string]interface{})
@@ -81,36 +151,24 @@ func (e *VmsExporter) Describe(ch chan<- *prometheus.Desc) {
 			if obj, ok := ent["uuid"]; ok {
 				uuid := obj.(string)
 				e.networkExporters[uuid] = NewVMsNetworkCollector(&e.api, vmName, uuid)
+				e.networkExporters[uuid].Configure(e.nicConfig)
+				e.networkExporters[uuid].SetMetricNaming(e.naming)
 			}
 		}
 
 		if stats != nil {
 			e.addCalculatedStats(ent, stats)
 			for key := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 
-				key = e.normalizeKey(key)
-
-				e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-					Namespace: e.namespace,
-					Name:      key, Help: "..."}, []string{"uuid", "host_uuid"})
-
-				e.metrics[key].Describe(ch)
+				e.describeStat(ch, key, e.labelNames())
 			}
 		}
 	}
-	for _, key := range e.fields {
-		key = e.normalizeKey(key)
-
-		log.Debugf("Register Key %s", key)
-
-		e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: e.namespace,
-			Name:      key, Help: "..."}, []string{"uuid", "host_uuid"})
-
-		e.metrics[key].Describe(ch)
+	for _, key := range e.allFields() {
+		e.describeStat(ch, key, e.labelNames())
 	}
 
 	e.DescribeNicsParallel(ch)
@@ -184,7 +242,6 @@ func (e *VmsExporter) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 	var key string
-	var g prometheus.Gauge
 
 	var entities []interface{} = nil
 	if obj, ok := e.result["entities"]; ok {
@@ -246,24 +303,26 @@ func (e *VmsExporter) Collect(ch chan<- prometheus.Metric) {
 			default:
 				obj := ent[property]
 				if obj != nil {
-					val = ent[property].(string)
+					val = fmt.Sprintf("%v", obj)
 				}
 			}
 			property_values = append(property_values, val)
 		}
-		g = e.metrics[key].WithLabelValues(property_values...)
-		g.Set(1)
-		g.Collect(ch)
+		e.collectProperties(ch, key, property_values)
+		e.collectIPAddresses(ch, ent["ipAddresses"], ent["uuid"].(string))
 
 		val := ent["hostUuid"]
 		var hostUUID string = ""
 		if val != nil {
 			hostUUID = val.(string)
 		}
+		if e.naming == METRIC_NAMING_V2 && len(hostUUID) > 0 {
+			e.collectStat(ch, KEY_VM_HOST_INFO, 1, ent["uuid"].(string), hostUUID)
+		}
 
 		if stats != nil {
 			for key, value := range stats {
-				if _, ok := e.filter_stats[key]; !ok {
+				if !e.statEnabled(key) {
 					continue
 				}
 				val := e.valueToFloat64(value)
@@ -271,36 +330,30 @@ func (e *VmsExporter) Collect(ch chan<- prometheus.Metric) {
 				if val == -1 {
 					continue
 				}
-				key = e.normalizeKey(key)
-				g := e.metrics[key].WithLabelValues(ent["uuid"].(string), hostUUID)
-				g.Set(val)
-				g.Collect(ch)
+				e.collectStat(ch, key, val, e.labelValues(ent["uuid"].(string), hostUUID)...)
 			}
 		}
 
-		for _, key := range e.fields {
-			normalized_key := e.normalizeKey(key)
-			log.Debugf("Collect Key %s", key)
-
-			g = e.metrics[normalized_key].WithLabelValues(ent["uuid"].(string), hostUUID)
+		for _, key := range e.allFields() {
+			e.api.Logger().Debugf("Collect Key %s", key)
 
+			val := e.valueToFloat64(ent[key])
 			if key == "powerState" {
 				if ent[key] == "on" {
-					g.Set(1)
+					val = 1
 				} else {
-					g.Set(0)
+					val = 0
 				}
-			} else {
-				g.Set(e.valueToFloat64(ent[key]))
 			}
-
-			g.Collect(ch)
-			log.Debugf("VMs data collected for VM=%s, VM UUID= %s", ent["vmName"], ent["uuid"])
+			e.collectStat(ch, key, val, e.labelValues(ent["uuid"].(string), hostUUID)...)
+			e.api.Logger().Debugf("VMs data collected for VM=%s, VM UUID= %s", ent["vmName"], ent["uuid"])
 		}
+		e.collectTimeSeries(ch, ent["uuid"].(string), e.labelValues(ent["uuid"].(string), hostUUID)...)
+		e.collectVmState(ch, ent, hostUUID)
 	}
 
 	for vmUUID, networkExporter := range e.networkExporters {
-		log.Debugf("Collect nic metrics for vm UUID: %s", vmUUID)
+		e.api.Logger().Debugf("Collect nic metrics for vm UUID: %s", vmUUID)
 		networkExporter.Collect(ch)
 	}
 }
@@ -317,6 +370,10 @@ func NewVmsCollector(_api *Nutanix, collectvmnics bool) *VmsExporter {
 			namespace:  "nutanix_vms",
 			fields:     []string{"memoryCapacityInBytes", "numVCpus", "powerState", "cpuReservedInHz"},
 			properties: []string{"uuid", "hostUuid", "vmName", "memoryCapacityInMB", "memoryReservedCapacityInMB", "numVCpus", "powerState", "cpuReservedInMHz", "diskCapacityInMB", "ipAddresses", "controllerVm"},
+			// host_uuid is a label of every VM metric, or of host_info under
+			// the v2 naming, and the power state a state set
+			volatileProperties: map[string]bool{"hostUuid": true, "memoryCapacityInMB": true, "memoryReservedCapacityInMB": true, "numVCpus": true, "powerState": true, "cpuReservedInMHz": true, "diskCapacityInMB": true, "ipAddresses": true},
+			volatileFields:     []string{"memoryReservedCapacityInBytes", "diskCapacityInBytes"},
 			filter_stats: map[string]bool{
 				"hypervisor_cpu_usage_ppm":         true,
 				"hypervisor_num_received_bytes":    true,
@@ -342,7 +399,7 @@ func (e *VmsExporter) DescribeNicsParallel(ch chan<- *prometheus.Desc) {
 			defer wg.Done()
 			semaphore <- struct{}{}        // Acquire a token
 			defer func() { <-semaphore }() // Release the token
-			log.Debugf("Describing vm nic metrics for vm UUID: %s", vmUUID)
+			e.api.Logger().Debugf("Describing vm nic metrics for vm UUID: %s", vmUUID)
 			exporter.Describe(ch)
 		}(vmUUID, networkExporter)
 	}
@@ -0,0 +1,252 @@
+package nutanix
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	KEY_VOLUME_GROUP_PROPERTIES  = "properties"
+	KEY_VOLUME_GROUP_ATTACHMENTS = "attachment_info"
+	METRIC_VG_NUM_DISKS          = "num_disks"
+	METRIC_VG_CAPACITY_BYTES     = "capacity_bytes"
+	METRIC_VG_VDISK_PREFIX       = "vdisk_"
+)
+
+// VolumeGroupsExporter
+type VolumeGroupsExporter struct {
+	*nutanixExporter
+	vdisks     *VirtualDisksExporter
+	vdiskStats map[string]map[string]interface{} // vdisk uuid -> stats
+}
+
+// fetchVdiskStats loads the stats of all virtual disks, keyed by the uuid also
+// used by VirtualDisksExporter, so volume group disks can be correlated. The
+// virtual disks already discovered by the VirtualDisksExporter are reused.
+func (e *VolumeGroupsExporter) fetchVdiskStats() {
+	e.vdiskStats = make(map[string]map[string]interface{})
+
+	var entities []interface{}
+	if e.vdisks != nil {
+		if e.vdisks.result == nil {
+			e.api.Logger().Debugf("No virtual disks discovered for volume groups")
+			return
+		}
+		entities, _ = e.vdisks.result["entities"].([]interface{})
+	} else {
+		var err error
+		entities, err = e.api.fetchAllPages("/virtual_disks", nil)
+		if err != nil {
+			e.api.Logger().Errorf("Virtual disk stats fetch for volume groups failed: %v", err)
+			return
+		}
+	}
+
+	for _, entRaw := range entities {
+		ent := entRaw.(map[string]interface{})
+		uuid, ok := ent["uuid"].(string)
+		if !ok {
+			continue
+		}
+		if stats, ok := ent["stats"].(map[string]interface{}); ok {
+			e.vdiskStats[uuid] = stats
+		}
+	}
+}
+
+// Describe - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *VolumeGroupsExporter) Describe(ch chan<- *prometheus.Desc) {
+	params := url.Values{}
+	params.Set("include_disk_size", "true")
+
+	entities, err := e.api.fetchAllPages("/volume_groups", params)
+	if err != nil {
+		e.result = nil
+		e.api.Logger().Error("Volume group discovery failed")
+		return
+	}
+
+	e.result = map[string]interface{}{"entities": entities}
+
+	key := KEY_VOLUME_GROUP_PROPERTIES
+	e.describeStat(ch, key, e.properties)
+
+	key = KEY_VOLUME_GROUP_ATTACHMENTS
+	e.metrics[key] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: e.namespace,
+		Name:      key,
+		Help:      "Volume group attachment to a VM or an external iSCSI initiator"}, []string{"uuid", "attachment_type", "vm_uuid", "iscsi_initiator_name"})
+	e.metrics[key].Describe(ch)
+
+	for _, key := range e.fields {
+		e.describeStat(ch, key, []string{"uuid"})
+	}
+
+	if len(entities) == 0 {
+		return
+	}
+
+	e.fetchVdiskStats()
+
+	// Stat patterns and "all" are resolved against the stats reported
+	enabled := make(map[string]bool, len(e.filter_stats))
+	for key := range e.filter_stats {
+		enabled[key] = true
+	}
+	for _, stats := range e.vdiskStats {
+		for key := range stats {
+			if e.statEnabled(key) {
+				enabled[key] = true
+			}
+		}
+	}
+	for key := range enabled {
+		name := METRIC_VG_VDISK_PREFIX + e.metricName(key)
+		e.metrics[name] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: e.namespace,
+			Name:      name,
+			Help:      e.metricHelp(key)}, []string{"uuid", "vdisk_uuid", "index"})
+		e.metrics[name].Describe(ch)
+	}
+}
+
+// Collect - Implement prometheus.Collector interface
+// See https://github.com/prometheus/client_golang/blob/master/prometheus/collector.go
+func (e *VolumeGroupsExporter) Collect(ch chan<- prometheus.Metric) {
+	if e.result == nil {
+		return
+	}
+	var entities []interface{} = nil
+	if obj, ok := e.result["entities"]; ok {
+		entities = obj.([]interface{})
+	}
+	if entities == nil {
+		return
+	}
+
+	for _, entity := range entities {
+		ent := entity.(map[string]interface{})
+		uuid, _ := ent["uuid"].(string)
+
+		var disks, attachments []interface{}
+		if obj, ok := ent["disk_list"].([]interface{}); ok {
+			disks = obj
+		}
+		if obj, ok := ent["attachment_list"].([]interface{}); ok {
+			attachments = obj
+		}
+
+		key := KEY_VOLUME_GROUP_PROPERTIES
+		var property_values []string
+		for _, property := range e.properties {
+			var val string = ""
+			switch property {
+			case "iscsi_initiator_names":
+				// Initiators allowed to connect from outside the cluster
+				if obj, ok := ent[property].([]interface{}); ok {
+					strarr := []string{}
+					for _, name := range obj {
+						strarr = append(strarr, fmt.Sprintf("%v", name))
+					}
+					val = strings.Join(strarr, ",")
+				}
+			default:
+				obj := ent[property]
+				if obj != nil {
+					val = fmt.Sprintf("%v", obj)
+				}
+			}
+			property_values = append(property_values, val)
+		}
+		g := e.metrics[key].WithLabelValues(property_values...)
+		g.Set(1)
+		g.Collect(ch)
+
+		for _, attRaw := range attachments {
+			att, ok := attRaw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			vmUUID, _ := att["vm_uuid"].(string)
+			initiator, _ := att["iscsi_initiator_name"].(string)
+			attachmentType := "external"
+			if len(vmUUID) > 0 {
+				attachmentType = "vm"
+			}
+			g := e.metrics[KEY_VOLUME_GROUP_ATTACHMENTS].WithLabelValues(uuid, attachmentType, vmUUID, initiator)
+			g.Set(1)
+			g.Collect(ch)
+		}
+
+		var capacity float64 = 0
+		for _, diskRaw := range disks {
+			disk, ok := diskRaw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			capacity += e.valueToFloat64(disk["vmdisk_size_bytes"])
+
+			vdiskUUID, _ := disk["vmdisk_uuid"].(string)
+			stats, ok := e.vdiskStats[vdiskUUID]
+			if !ok {
+				e.api.Logger().Debugf("No virtual disk stats for volume group %s disk %s", uuid, vdiskUUID)
+				continue
+			}
+			index := fmt.Sprintf("%v", disk["index"])
+			for key, value := range stats {
+				if !e.statEnabled(key) {
+					continue
+				}
+
+				val := e.valueToFloat64(value)
+				// ignore stats which are not available
+				if val == -1 {
+					continue
+				}
+				g := e.metrics[METRIC_VG_VDISK_PREFIX+e.metricName(key)].WithLabelValues(uuid, vdiskUUID, index)
+				g.Set(e.metricValue(key, val))
+				g.Collect(ch)
+			}
+		}
+
+		for _, key := range e.fields {
+			switch key {
+			case METRIC_VG_NUM_DISKS:
+				e.collectStat(ch, key, float64(len(disks)), uuid)
+			case METRIC_VG_CAPACITY_BYTES:
+				e.collectStat(ch, key, capacity, uuid)
+			default:
+				e.collectStat(ch, key, e.valueToFloat64(ent[key]), uuid)
+			}
+		}
+		e.api.Logger().Debugf("Volume group data collected for volume group: %s (UUID: %s)", ent["name"], uuid)
+	}
+}
+
+// NewVolumeGroupsCollector - vdisks is optional and used to reuse the virtual
+// disks already discovered by the VirtualDisksExporter
+func NewVolumeGroupsCollector(_api *Nutanix, vdisks *VirtualDisksExporter) *VolumeGroupsExporter {
+	return &VolumeGroupsExporter{
+		vdisks: vdisks,
+		nutanixExporter: &nutanixExporter{
+			api:        *_api,
+			metrics:    make(map[string]*prometheus.GaugeVec),
+			namespace:  "nutanix_volume_groups",
+			fields:     []string{METRIC_VG_NUM_DISKS, METRIC_VG_CAPACITY_BYTES},
+			properties: []string{"uuid", "name", "description", "iscsi_target", "iscsi_initiator_names", "flash_mode_enabled", "is_shared"},
+			filter_stats: map[string]bool{
+				"controller_total_read_io_size_kbytes":  true,
+				"controller_total_io_size_kbytes":       true,
+				"controller_num_read_io":                true,
+				"controller_num_write_io":               true,
+				"controller_avg_read_io_latency_usecs":  true,
+				"controller_avg_write_io_latency_usecs": true,
+				"controller_user_bytes":                 true,
+			},
+		},
+	}
+}
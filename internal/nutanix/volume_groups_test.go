@@ -0,0 +1,100 @@
+package nutanix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// newVolumeGroupsPrism serves two volume groups with disks and the virtual
+// disks backing them, counting the virtual disk requests
+func newVolumeGroupsPrism(t *testing.T, vdiskRequests *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/volume_groups/"):
+			// Disk sizes are only reported with include_disk_size
+			if r.URL.Query().Get("include_disk_size") != "true" {
+				w.Write([]byte(`{"metadata": {"grand_total_entities": 0}, "entities": []}`))
+				return
+			}
+			w.Write([]byte(`{"metadata": {"grand_total_entities": 2, "end_index": 2}, "entities": [
+				{"uuid": "vg1", "name": "db", "flash_mode_enabled": true, "disk_list": [
+					{"index": 0, "vmdisk_uuid": "vd1", "vmdisk_size_bytes": 1073741824},
+					{"index": 1, "vmdisk_uuid": "vd2", "vmdisk_size_bytes": "2147483648"}],
+				 "attachment_list": [{"vm_uuid": "vm1"}, {"iscsi_initiator_name": "iqn.2026-01.local:host"}]},
+				{"uuid": "vg2", "name": "empty", "disk_list": []}]}`))
+		case strings.HasSuffix(r.URL.Path, "/virtual_disks/"):
+			atomic.AddInt32(vdiskRequests, 1)
+			w.Write([]byte(`{"metadata": {"grand_total_entities": 2, "end_index": 2}, "entities": [
+				{"uuid": "vd1", "stats": {"controller_num_read_io": "100", "controller_total_io_size_kbytes": "10"}},
+				{"uuid": "vd2", "stats": {"controller_num_read_io": "50", "controller_total_io_size_kbytes": "-1"}}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVolumeGroupsAggregation(t *testing.T) {
+	var vdiskRequests int32
+	server := newVolumeGroupsPrism(t, &vdiskRequests)
+	collector := NewVolumeGroupsCollector(NewNutanix(server.URL, "user", "pass", 2), nil)
+
+	expected := `
+# HELP nutanix_volume_groups_capacity_bytes Capacity of the volume group
+# TYPE nutanix_volume_groups_capacity_bytes gauge
+nutanix_volume_groups_capacity_bytes{uuid="vg1"} 3.221225472e+09
+nutanix_volume_groups_capacity_bytes{uuid="vg2"} 0
+# HELP nutanix_volume_groups_num_disks Disks of the volume group
+# TYPE nutanix_volume_groups_num_disks gauge
+nutanix_volume_groups_num_disks{uuid="vg1"} 2
+nutanix_volume_groups_num_disks{uuid="vg2"} 0
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"nutanix_volume_groups_capacity_bytes", "nutanix_volume_groups_num_disks")
+	assert.NoError(t, err)
+
+	// Per vdisk stats, unavailable stats (-1) are skipped
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "nutanix_volume_groups_vdisk_controller_num_read_io"))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "nutanix_volume_groups_vdisk_controller_total_io_size_kbytes"))
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "nutanix_volume_groups_attachment_info"))
+	// One virtual disk request per scrape
+	assert.Equal(t, int32(4), atomic.LoadInt32(&vdiskRequests))
+}
+
+func TestVolumeGroupsExtraFields(t *testing.T) {
+	var vdiskRequests int32
+	server := newVolumeGroupsPrism(t, &vdiskRequests)
+	collector := NewVolumeGroupsCollector(NewNutanix(server.URL, "user", "pass", 2), nil)
+	collector.Configure(&CollectorConfig{ExtraFields: []string{"flash_mode_enabled"}})
+
+	// Fields without dedicated handling are read from the entity
+	expected := `
+# HELP nutanix_volume_groups_flash_mode_enabled Prism attribute flash_mode_enabled
+# TYPE nutanix_volume_groups_flash_mode_enabled gauge
+nutanix_volume_groups_flash_mode_enabled{uuid="vg1"} 1
+nutanix_volume_groups_flash_mode_enabled{uuid="vg2"} 0
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "nutanix_volume_groups_flash_mode_enabled")
+	assert.NoError(t, err)
+}
+
+func TestVolumeGroupsReuseVirtualDisks(t *testing.T) {
+	var vdiskRequests int32
+	server := newVolumeGroupsPrism(t, &vdiskRequests)
+	api := NewNutanix(server.URL, "user", "pass", 2)
+
+	vdisks := NewVirtualDisksCollector(api)
+	testutil.CollectAndCount(vdisks)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&vdiskRequests))
+
+	collector := NewVolumeGroupsCollector(api, vdisks)
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "nutanix_volume_groups_vdisk_controller_num_read_io"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&vdiskRequests))
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"nutanix-exporter/internal/nutanix"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Cluster is a section of the config file
+type Cluster struct {
+	Host                string          `yaml:"nutanix_host"`
+	Username            string          `yaml:"nutanix_user"`
+	Password            string          `yaml:"nutanix_password"`
+	LogLevel            string          `yaml:"log_level"`
+	MaxParallelRequests int             `yaml:"max_parallel_requests"`
+	Collect             map[string]bool `yaml:"collect"`
+	VmCategories        VmCategories    `yaml:"vm_categories"`
+	// Entity filters per collector (vms, hosts, storage_containers)
+	Filters map[string]*nutanix.EntityFilter `yaml:"filters"`
+	// Stats, fields and properties per collector
+	Collectors map[string]*nutanix.CollectorConfig `yaml:"collectors"`
+	// Naming scheme of the metrics, v1 (default) or v2
+	MetricNaming string `yaml:"metric_naming"`
+	// Series limits of the section and per collector
+	SeriesLimits nutanix.SeriesLimits `yaml:"series_limits"`
+	// Record or replay the Prism responses
+	Recording *nutanix.Recording `yaml:"recording"`
+}
+
+// VmCategories configures the lookup of VM categories from Prism Central.
+// Host and credentials default to the ones of the section.
+type VmCategories struct {
+	Host     string        `yaml:"prism_central_host"`
+	Username string        `yaml:"prism_central_user"`
+	Password string        `yaml:"prism_central_password"`
+	TTL      time.Duration `yaml:"ttl"`
+	Labels   []string      `yaml:"labels"`
+}
+
+// ParseConfig unmarshals and validates the config file
+func ParseConfig(file []byte) (map[string]Cluster, error) {
+	var config map[string]Cluster
+	if err := yaml.Unmarshal(file, &config); err != nil {
+		return nil, err
+	}
+	for sectionName, conf := range config {
+		for collector, filter := range conf.Filters {
+			switch collector {
+			case "vms", "hosts", "storage_containers":
+			default:
+				return nil, fmt.Errorf("section %s: filters are not supported for collector %s", sectionName, collector)
+			}
+			if err := filter.Compile(); err != nil {
+				return nil, fmt.Errorf("section %s: invalid %s filter: %v", sectionName, collector, err)
+			}
+		}
+		switch conf.MetricNaming {
+		case "", nutanix.METRIC_NAMING_V1, nutanix.METRIC_NAMING_V2:
+		default:
+			return nil, fmt.Errorf("section %s: unknown metric_naming %s", sectionName, conf.MetricNaming)
+		}
+		if conf.SeriesLimits.Section < 0 {
+			return nil, fmt.Errorf("section %s: invalid series limit %d", sectionName, conf.SeriesLimits.Section)
+		}
+		for collector, limit := range conf.SeriesLimits.Collectors {
+			if limit < 0 {
+				return nil, fmt.Errorf("section %s: invalid series limit %d for collector %s", sectionName, limit, collector)
+			}
+		}
+		for collector, collectorConf := range conf.Collectors {
+			if err := collectorConf.Validate(collector); err != nil {
+				return nil, fmt.Errorf("section %s: invalid collector config: %v", sectionName, err)
+			}
+		}
+		if err := nutanix.ValidateCategoryLabels(conf.VmCategories.Labels); err != nil {
+			return nil, fmt.Errorf("section %s: invalid vm_categories labels: %v", sectionName, err)
+		}
+		if conf.Recording != nil {
+			if err := conf.Recording.Validate(); err != nil {
+				return nil, fmt.Errorf("section %s: %v", sectionName, err)
+			}
+		}
+	}
+	return config, nil
+}
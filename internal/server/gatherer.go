@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"nutanix-exporter/internal/nutanix"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// namedCollector is a collector supporting the metric naming schemes
+type namedCollector interface {
+	prometheus.Collector
+	SetMetricNaming(naming string)
+}
+
+// CollectorNames lists the collectors of a section in registration order
+var CollectorNames = []string{
+	"storage_containers", "vm_categories", "vms", "hosts", "cluster", "snapshots",
+	"virtual_disks", "volume_groups", "images", "networks", "tasks",
+	"fault_tolerance", "events", "health_checks", "licenses", "software",
+}
+
+// PrismCentralClient creates the client of the Prism Central the VM
+// categories are fetched from. Host and credentials default to the ones of
+// the section.
+func PrismCentralClient(conf Cluster, newClient ClientFactory) *nutanix.Nutanix {
+	pc := conf
+	if len(conf.VmCategories.Host) > 0 {
+		pc.Host = conf.VmCategories.Host
+	}
+	if len(conf.VmCategories.Username) > 0 {
+		pc.Username, pc.Password = conf.VmCategories.Username, conf.VmCategories.Password
+	}
+	return newClient(pc)
+}
+
+// SectionGatherer registers the collectors of the section and returns the
+// gatherer of their metrics and a function listing the collectors whose Prism
+// requests failed. The Prism clients are created by newClient and their
+// requests canceled with ctx. The collectors and their registration are
+// logged to logger. only selects collectors regardless of the collect
+// settings when not empty.
+func SectionGatherer(ctx context.Context, registry *prometheus.Registry, conf Cluster, newClient ClientFactory, logger *log.Logger, only map[string]bool) (prometheus.Gatherer, func() []string) {
+	nutanixAPI := newClient(conf).WithContext(ctx).WithLogger(logger)
+	guard := nutanix.NewSeriesGuard(nutanixAPI, conf.SeriesLimits)
+	// enabled returns true if the collector is selected, or enabled in the
+	// config when no collectors are selected
+	enabled := func(name string, byDefault bool) bool {
+		if len(only) > 0 {
+			return only[name]
+		}
+		val, exist := conf.Collect[name]
+		return val || (!exist && byDefault)
+	}
+	// Every collector gets its own client to tell which collectors failed
+	clients := make(map[string]*nutanix.Nutanix)
+	client := func(name string) *nutanix.Nutanix {
+		clients[name] = nutanixAPI.Fork()
+		return clients[name]
+	}
+	register := func(name string, c namedCollector) {
+		c.SetMetricNaming(conf.MetricNaming)
+		guard.MustRegister(name, c)
+	}
+
+	if enabled("storage_containers", true) {
+		logger.Debugf("Register StorageContainersCollector")
+		storageContainersCollector := nutanix.NewStorageContainersCollector(client("storage_containers"))
+		storageContainersCollector.SetFilter(conf.Filters["storage_containers"])
+		storageContainersCollector.Configure(conf.Collectors["storage_containers"])
+		register("storage_containers", storageContainersCollector)
+	}
+	var vmCategories *nutanix.VmCategories
+	if enabled("vm_categories", false) {
+		clients["vm_categories"] = PrismCentralClient(conf, newClient).WithContext(ctx).WithLogger(logger)
+		vmCategories = nutanix.NewVmCategories(clients["vm_categories"], nutanixAPI, conf.VmCategories.TTL)
+		logger.Debugf("Register VmCategoriesCollector")
+		register("vm_categories", nutanix.NewVmCategoriesCollector(nutanixAPI, vmCategories))
+	}
+	var vmsCollector *nutanix.VmsExporter
+	if enabled("vms", true) {
+		logger.Debugf("Register VmsCollector")
+		vmsCollector = nutanix.NewVmsCollector(client("vms"), conf.Collect["vmnics"])
+		vmsCollector.SetFilter(conf.Filters["vms"])
+		vmsCollector.Configure(conf.Collectors["vms"])
+		vmsCollector.ConfigureNics(conf.Collectors["vmnics"])
+		if vmCategories != nil {
+			vmsCollector.WithCategoryLabels(vmCategories, conf.VmCategories.Labels)
+		}
+		register("vms", vmsCollector)
+	}
+	if enabled("hosts", true) {
+		// Registered after the VmsCollector to reuse its controller VMs
+		logger.Debugf("Register HostsCollector")
+		hostsCollector := nutanix.NewHostsCollector(client("hosts"), conf.Collect["hostnics"], vmsCollector)
+		hostsCollector.SetFilter(conf.Filters["hosts"])
+		hostsCollector.Configure(conf.Collectors["hosts"])
+		hostsCollector.ConfigureNics(conf.Collectors["hostnics"])
+		register("hosts", hostsCollector)
+	}
+	if enabled("cluster", true) {
+		logger.Debugf("Register ClusterCollector")
+		clusterCollector := nutanix.NewClusterCollector(client("cluster"))
+		clusterCollector.Configure(conf.Collectors["cluster"])
+		register("cluster", clusterCollector)
+	}
+	if enabled("snapshots", true) {
+		logger.Debugf("Register Snapshots")
+		snapshotsCollector := nutanix.NewSnapshotsCollector(client("snapshots"))
+		snapshotsCollector.Configure(conf.Collectors["snapshots"])
+		register("snapshots", snapshotsCollector)
+	}
+	var virtualDisksCollector *nutanix.VirtualDisksExporter
+	if enabled("virtual_disks", true) {
+		logger.Debugf("Register VirtualDisksCollector")
+		virtualDisksCollector = nutanix.NewVirtualDisksCollector(client("virtual_disks"))
+		virtualDisksCollector.Configure(conf.Collectors["virtual_disks"])
+		register("virtual_disks", virtualDisksCollector)
+	}
+	// Optional collectors, only registered when explicitly enabled
+	if enabled("volume_groups", false) {
+		// Registered after the VirtualDisksCollector to reuse its virtual disks
+		logger.Debugf("Register VolumeGroupsCollector")
+		volumeGroupsCollector := nutanix.NewVolumeGroupsCollector(client("volume_groups"), virtualDisksCollector)
+		volumeGroupsCollector.Configure(conf.Collectors["volume_groups"])
+		register("volume_groups", volumeGroupsCollector)
+	}
+	if enabled("images", false) {
+		logger.Debugf("Register ImagesCollector")
+		imagesCollector := nutanix.NewImagesCollector(client("images"))
+		imagesCollector.Configure(conf.Collectors["images"])
+		register("images", imagesCollector)
+	}
+	if enabled("networks", false) {
+		// Registered after the VmsCollector to reuse its VM NICs
+		logger.Debugf("Register NetworksCollector")
+		networksCollector := nutanix.NewNetworksCollector(client("networks"), vmsCollector)
+		networksCollector.Configure(conf.Collectors["networks"])
+		register("networks", networksCollector)
+	}
+	if enabled("tasks", false) {
+		logger.Debugf("Register TasksCollector")
+		register("tasks", nutanix.NewTasksCollector(client("tasks")))
+	}
+	if enabled("fault_tolerance", false) {
+		logger.Debugf("Register FaultToleranceCollector")
+		register("fault_tolerance", nutanix.NewFaultToleranceCollector(client("fault_tolerance")))
+	}
+	if enabled("events", false) {
+		logger.Debugf("Register EventsCollector")
+		register("events", nutanix.NewEventsCollector(client("events")))
+	}
+	if enabled("health_checks", false) {
+		logger.Debugf("Register HealthChecksCollector")
+		register("health_checks", nutanix.NewHealthChecksCollector(client("health_checks")))
+	}
+	if enabled("licenses", false) {
+		logger.Debugf("Register LicensesCollector")
+		register("licenses", nutanix.NewLicensesCollector(client("licenses")))
+	}
+	if enabled("software", false) {
+		logger.Debugf("Register SoftwareCollector")
+		register("software", nutanix.NewSoftwareCollector(client("software")))
+	}
+	registry.MustRegister(nutanix.NewExporterStatsCollector(nutanixAPI))
+
+	failed := func() []string {
+		names := []string{}
+		for name, c := range clients {
+			if c.Failures() > 0 {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+	return guard.Gatherer(registry), failed
+}
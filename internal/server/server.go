@@ -0,0 +1,260 @@
+// Package server implements the /metrics endpoint of the exporter: it
+// resolves the requested section of the config, registers its collectors and
+// serves their metrics or the health metrics of the exporter.
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"nutanix-exporter/internal/nutanix"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DEFAULT_SECTION = "default"
+	// HEALTH_UUID_FALLBACK is the uuid of the health metrics of sections
+	// without Prism host
+	HEALTH_UUID_FALLBACK = "exporter-health"
+)
+
+// ClientFactory creates the Prism client of a section
+type ClientFactory func(conf Cluster) *nutanix.Nutanix
+
+// Option configures a Server
+type Option func(*Server)
+
+// Server is the /metrics handler of the sections of a config
+type Server struct {
+	config    map[string]Cluster
+	now       func() time.Time
+	newClient ClientFactory
+	log       *log.Logger
+
+	// Cluster UUID per section, fetched once for the health metrics
+	uuidsMu sync.RWMutex
+	uuids   map[string]string
+}
+
+// WithClock sets the clock measuring the collection durations
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// WithClientFactory sets the factory of the Prism clients
+func WithClientFactory(f ClientFactory) Option {
+	return func(s *Server) {
+		s.newClient = f
+	}
+}
+
+// WithLogger sets the logger of the handler. The requests of a section are
+// logged through a copy of it at the log level of the section. The collectors
+// log to the standard logger.
+func WithLogger(l *log.Logger) Option {
+	return func(s *Server) {
+		s.log = l
+	}
+}
+
+// NewClient creates the Prism client of a section, recording or replaying
+// its requests if configured
+func NewClient(conf Cluster) *nutanix.Nutanix {
+	return nutanix.NewNutanix(conf.Host, conf.Username, conf.Password, conf.MaxParallelRequests).WithRecording(conf.Recording)
+}
+
+// New - create the server of a parsed config
+func New(config map[string]Cluster, opts ...Option) *Server {
+	s := &Server{
+		config:    config,
+		now:       time.Now,
+		newClient: NewClient,
+		log:       log.StandardLogger(),
+		uuids:     make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SectionLogger returns a logger writing like base, at the log level of the
+// section. base is not modified, so concurrent scrapes of sections with
+// different log levels do not change the level of each other.
+func SectionLogger(base *log.Logger, conf Cluster) *log.Logger {
+	l := log.New()
+	l.Out = base.Out
+	l.Formatter = base.Formatter
+	l.Hooks = base.Hooks
+	l.ReportCaller = base.ReportCaller
+	l.ExitFunc = base.ExitFunc
+	switch strings.ToLower(conf.LogLevel) {
+	case "debug":
+		l.SetLevel(log.DebugLevel)
+	case "trace":
+		l.SetLevel(log.TraceLevel)
+	default:
+		l.SetLevel(log.InfoLevel)
+	}
+	return l
+}
+
+// ServeHTTP - Implement http.Handler interface
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	section := params.Get("section")
+	healthOnly := strings.EqualFold(params.Get("health"), "true")
+
+	// If section is not provided, default to "default" section
+	if len(section) == 0 {
+		// If health=true with no section, collect health metrics for all sections only
+		if healthOnly {
+			s.serveAllHealth(w, r)
+			return
+		}
+		// No section and no health=true: default to "default" section (original behavior)
+		// This preserves backward compatibility - returns only regular metrics for default section
+		section = DEFAULT_SECTION
+	}
+	s.serveSection(w, r, section, healthOnly)
+}
+
+// healthSectionKey returns the key of the health tracking of a section. It
+// must match what nutanix.go uses in g.url, the host URL as-is.
+func healthSectionKey(sectionName string, conf Cluster) string {
+	if len(conf.Host) == 0 {
+		return sectionName // Fallback to section name if host is empty
+	}
+	return conf.Host
+}
+
+// clusterUUID returns the cluster UUID of a section for the health metrics,
+// cached after the first successful lookup. The section name is returned when
+// the lookup fails.
+func (s *Server) clusterUUID(ctx context.Context, sectionName string, conf Cluster) string {
+	s.uuidsMu.RLock()
+	uuid, found := s.uuids[sectionName]
+	s.uuidsMu.RUnlock()
+	if found {
+		s.log.Debugf("Using cached cluster UUID for section %s: %s", sectionName, uuid)
+		return uuid
+	}
+	if len(conf.Host) == 0 {
+		return HEALTH_UUID_FALLBACK
+	}
+
+	uuid, err := s.newClient(conf).WithContext(ctx).GetClusterUUID()
+	if err != nil {
+		s.log.Debugf("Failed to get cluster UUID for section %s: %v, using section name as fallback", sectionName, err)
+		return sectionName
+	}
+	s.uuidsMu.Lock()
+	s.uuids[sectionName] = uuid
+	s.uuidsMu.Unlock()
+	s.log.Infof("Successfully fetched and cached cluster UUID for section %s: %s", sectionName, uuid)
+	return uuid
+}
+
+// healthCollectors collects the health metrics of several sections. The
+// collectors share their descriptors and can not be registered one by one.
+type healthCollectors []*nutanix.ExporterHealthCollector
+
+// Describe - Implement prometheus.Collector interface
+func (c healthCollectors) Describe(ch chan<- *prometheus.Desc) {
+	if len(c) > 0 {
+		c[0].Describe(ch)
+	}
+}
+
+// Collect - Implement prometheus.Collector interface
+func (c healthCollectors) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c {
+		collector.Collect(ch)
+	}
+}
+
+// serveAllHealth serves the health metrics of all configured sections, and
+// no regular metrics
+func (s *Server) serveAllHealth(w http.ResponseWriter, r *http.Request) {
+	s.log.Infof("health=true with no section specified, collecting health metrics for all configured sections")
+	collectors := healthCollectors{}
+	for sectionName, conf := range s.config {
+		uuid := s.clusterUUID(r.Context(), sectionName, conf)
+		collectors = append(collectors, nutanix.NewExporterHealthCollector(healthSectionKey(sectionName, conf), uuid, uuid))
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors)
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// serveSection serves the metrics of a section, or its health metrics only
+func (s *Server) serveSection(w http.ResponseWriter, r *http.Request, section string, healthOnly bool) {
+	collStart := s.now()
+	// Section is always provided as host IP (e.g., "10.20.10.40") and should match config key
+	conf, ok := s.config[section]
+	logger := SectionLogger(s.log, conf)
+	logger.Infof("Section: %s", section)
+
+	healthKey := section
+	if ok {
+		healthKey = healthSectionKey(section, conf)
+	} else if !healthOnly {
+		logger.Warnf("Section '%s' not found in config file", section)
+	}
+
+	// Always track collection cycles (for both regular and health metrics)
+	// This ensures health metrics are updated even when collecting regular metrics
+	// Health metrics are only exposed when health=true is explicitly requested
+	if !nutanix.MarkCollectionStart(healthKey) {
+		// Collection already running, return early without tracking end
+		// (MarkCollectionEnd should only be called for collections that actually started)
+		return
+	}
+
+	// Track collection success - starts as true, set to false on errors
+	collectionSuccess := true
+	defer func() {
+		nutanix.MarkCollectionEnd(healthKey, collectionSuccess, s.now().Sub(collStart))
+	}()
+
+	registry := prometheus.NewRegistry()
+	if healthOnly {
+		// Config section not found, use section name as fallback
+		uuid := section
+		if ok {
+			uuid = s.clusterUUID(r.Context(), section, conf)
+		}
+		registry.MustRegister(nutanix.NewExporterHealthCollector(healthKey, uuid, uuid))
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+		return
+	}
+
+	// Regular metrics collection, health metrics are tracked but not exposed
+	if !ok || len(conf.Host) == 0 {
+		logger.Errorf("Cannot create Nutanix API client: missing configuration for section '%s'", section)
+		http.Error(w, fmt.Sprintf("Section '%s' not found in config", section), http.StatusNotFound)
+		return
+	}
+	// The Prism requests are canceled when the scrape is
+	gatherer, _ := SectionGatherer(r.Context(), registry, conf, s.newClient, logger, nil)
+
+	h := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+	// Track if HTTP response writing fails
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				collectionSuccess = false
+				logger.Errorf("Panic while serving metrics: %v", r)
+			}
+		}()
+		h.ServeHTTP(w, r)
+	}()
+}
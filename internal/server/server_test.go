@@ -0,0 +1,326 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"nutanix-exporter/internal/fakeprism"
+	"nutanix-exporter/internal/nutanix"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPrism starts a fake Prism and returns a section pointing to it
+func newPrism(t *testing.T, conf fakeprism.Config, section Cluster) (*fakeprism.Server, Cluster) {
+	fake := fakeprism.New(conf)
+	prism := httptest.NewServer(fake)
+	t.Cleanup(prism.Close)
+	section.Host = prism.URL
+	section.Username, section.Password = "admin", "secret"
+	return fake, section
+}
+
+// get serves a request of the server and returns the status and body
+func get(t *testing.T, s *Server, query string) (int, string) {
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics?" + query)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+// scrape serves the /metrics handler of a section of the fake Prism and
+// returns the status and body of a scrape
+func scrape(t *testing.T, prismConf fakeprism.Config, section Cluster, query string) (int, string) {
+	_, section = newPrism(t, prismConf, section)
+	return get(t, New(map[string]Cluster{"e2e": section}), query)
+}
+
+// countSeries returns the number of samples of a metric
+func countSeries(body, name string) int {
+	count := 0
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, name+"{") || strings.HasPrefix(line, name+" ") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestMetricsEndToEnd(t *testing.T) {
+	status, body := scrape(t, fakeprism.DefaultConfig(), Cluster{}, "section=e2e")
+	require.Equal(t, http.StatusOK, status)
+
+	assert.Equal(t, 1, countSeries(body, "nutanix_cluster_hypervisor_cpu_usage_ppm"))
+	assert.Equal(t, 3, countSeries(body, "nutanix_hosts_hypervisor_cpu_usage_ppm"))
+	// Guest VMs and controller VMs
+	assert.Equal(t, 23, countSeries(body, "nutanix_vms_hypervisor_cpu_usage_ppm"))
+	assert.Equal(t, 2, countSeries(body, "nutanix_storage_containers_controller_num_read_io"))
+	assert.Equal(t, 40, countSeries(body, "nutanix_vdisks_controller_num_read_io"))
+	assert.Contains(t, body, "nutanix_snapshots_")
+	// Health metrics are only served with health=true
+	assert.NotContains(t, body, "nutanix_exporter_TotalPollCycles_C")
+}
+
+func TestMetricsPaging(t *testing.T) {
+	conf := fakeprism.DefaultConfig()
+	conf.VMs = 250
+	status, body := scrape(t, conf, Cluster{Collect: map[string]bool{"vms": true}}, "section=e2e")
+	require.Equal(t, http.StatusOK, status)
+
+	assert.Equal(t, 253, countSeries(body, "nutanix_vms_hypervisor_cpu_usage_ppm"))
+}
+
+func TestMetricsNics(t *testing.T) {
+	conf := fakeprism.DefaultConfig()
+	conf.NICsPerEntity = 2
+	section := Cluster{Collect: map[string]bool{"hosts": true, "vms": true, "hostnics": true, "vmnics": true}}
+	status, body := scrape(t, conf, section, "section=e2e")
+	require.Equal(t, http.StatusOK, status)
+
+	assert.Equal(t, 6, countSeries(body, "nutanix_hostnics_network_received_bytes"))
+	assert.Equal(t, 40, countSeries(body, "nutanix_vmnics_network_received_bytes"))
+}
+
+func TestMetricsNicsConfig(t *testing.T) {
+	conf := fakeprism.DefaultConfig()
+	conf.NICsPerEntity = 2
+	section := Cluster{
+		Collect: map[string]bool{"vms": true, "vmnics": true},
+		Collectors: map[string]*nutanix.CollectorConfig{
+			"vmnics": {Stats: &nutanix.StatsList{Names: []string{"network.received_bytes"}}},
+		},
+	}
+	status, body := scrape(t, conf, section, "section=e2e")
+	require.Equal(t, http.StatusOK, status)
+
+	assert.Equal(t, 40, countSeries(body, "nutanix_vmnics_network_received_bytes"))
+	assert.Equal(t, 0, countSeries(body, "nutanix_vmnics_network_transmitted_bytes"))
+}
+
+func TestMetricsCvmStateReusesVms(t *testing.T) {
+	fake := fakeprism.New(fakeprism.DefaultConfig())
+	var vmLists int32
+	prism := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/v1/vms/") {
+			atomic.AddInt32(&vmLists, 1)
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer prism.Close()
+	controllerVM := true
+	section := Cluster{
+		Host: prism.URL, Username: "admin", Password: "secret",
+		Collect: map[string]bool{"hosts": true, "vms": true},
+		// The controller VMs of the hosts are known despite the VM filter
+		Filters: map[string]*nutanix.EntityFilter{"vms": {Exclude: []nutanix.FilterRule{{ControllerVM: &controllerVM}}}},
+	}
+
+	status, body := get(t, New(map[string]Cluster{"e2e": section}), "section=e2e")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 20, countSeries(body, "nutanix_vms_hypervisor_cpu_usage_ppm"))
+	up := 0
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "nutanix_hosts_cvm_state{") && strings.Contains(line, `state="up"`) && strings.HasSuffix(line, " 1") {
+			up++
+		}
+	}
+	assert.Equal(t, 3, up)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&vmLists))
+}
+
+func TestMetricsVmHostV2(t *testing.T) {
+	section := Cluster{Collect: map[string]bool{"vms": true}, MetricNaming: nutanix.METRIC_NAMING_V2}
+	status, body := scrape(t, fakeprism.DefaultConfig(), section, "section=e2e")
+	require.Equal(t, http.StatusOK, status)
+
+	// The host is only a label of the host_info metric, of the VMs powered on
+	assert.Equal(t, 23, countSeries(body, "nutanix_vms_hypervisor_cpu_usage_ratio"))
+	assert.Equal(t, 21, countSeries(body, "nutanix_vms_host_info"))
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "nutanix_vms_") && !strings.HasPrefix(line, "nutanix_vms_host_info") {
+			assert.NotContains(t, line, "host_uuid=")
+		}
+	}
+}
+
+func TestMetricsFailingEndpoint(t *testing.T) {
+	conf := fakeprism.DefaultConfig()
+	conf.FailPaths = []string{"v2.0/snapshots"}
+	status, body := scrape(t, conf, Cluster{}, "section=e2e")
+	require.Equal(t, http.StatusOK, status)
+
+	// The other collectors are not affected
+	assert.Equal(t, 3, countSeries(body, "nutanix_hosts_hypervisor_cpu_usage_ppm"))
+	assert.Equal(t, 23, countSeries(body, "nutanix_vms_hypervisor_cpu_usage_ppm"))
+}
+
+func TestSectionLookup(t *testing.T) {
+	small := fakeprism.DefaultConfig()
+	small.VMs = 5
+	_, smallSection := newPrism(t, small, Cluster{Collect: map[string]bool{"vms": true}})
+	_, defaultSection := newPrism(t, fakeprism.DefaultConfig(), Cluster{Collect: map[string]bool{"vms": true}})
+
+	var mu sync.Mutex
+	hosts := []string{}
+	s := New(map[string]Cluster{"small": smallSection, DEFAULT_SECTION: defaultSection},
+		WithClientFactory(func(conf Cluster) *nutanix.Nutanix {
+			mu.Lock()
+			hosts = append(hosts, conf.Host)
+			mu.Unlock()
+			return NewClient(conf)
+		}))
+
+	status, body := get(t, s, "section=small")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 8, countSeries(body, "nutanix_vms_hypervisor_cpu_usage_ppm"))
+	assert.Equal(t, []string{smallSection.Host}, hosts)
+
+	// Without section the default section is served
+	status, body = get(t, s, "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 23, countSeries(body, "nutanix_vms_hypervisor_cpu_usage_ppm"))
+	assert.Equal(t, []string{smallSection.Host, defaultSection.Host}, hosts)
+}
+
+func TestPrismCentralClient(t *testing.T) {
+	var pcRequests int32
+	pc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pcRequests, 1)
+		w.Write([]byte(`{"metadata": {"total_matches": 0}, "entities": []}`))
+	}))
+	defer pc.Close()
+	_, section := newPrism(t, fakeprism.DefaultConfig(), Cluster{
+		Collect:      map[string]bool{"vm_categories": true},
+		VmCategories: VmCategories{Host: pc.URL},
+	})
+
+	var mu sync.Mutex
+	hosts := map[string]Cluster{}
+	s := New(map[string]Cluster{"e2e": section},
+		WithClientFactory(func(conf Cluster) *nutanix.Nutanix {
+			mu.Lock()
+			hosts[conf.Host] = conf
+			mu.Unlock()
+			return NewClient(conf)
+		}))
+
+	status, _ := get(t, s, "section=e2e")
+	require.Equal(t, http.StatusOK, status)
+	// The Prism Central client is created by the factory too, with the
+	// credentials of the section
+	require.Contains(t, hosts, pc.URL)
+	assert.Equal(t, "admin", hosts[pc.URL].Username)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&pcRequests))
+}
+
+func TestUnknownSection(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	_, section := newPrism(t, fakeprism.DefaultConfig(), Cluster{})
+	s := New(map[string]Cluster{"e2e": section},
+		WithLogger(logger),
+		WithClientFactory(func(conf Cluster) *nutanix.Nutanix {
+			t.Errorf("unexpected client of %s", conf.Host)
+			return NewClient(conf)
+		}))
+
+	status, _ := get(t, s, "section=unknown")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Contains(t, out.String(), "Section 'unknown' not found in config file")
+
+	// Health metrics of an unknown section are labelled with its name
+	status, body := get(t, s, "section=unknown&health=true")
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `nutanix_exporter_TotalPollCycles_C{cluster_uuid="unknown",section="unknown",uuid="unknown"} 1`)
+}
+
+func TestSectionLogLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	_, debug := newPrism(t, fakeprism.DefaultConfig(), Cluster{LogLevel: "debug", Collect: map[string]bool{"cluster": true}})
+	_, info := newPrism(t, fakeprism.DefaultConfig(), Cluster{Collect: map[string]bool{"cluster": true}})
+	s := New(map[string]Cluster{"debug": debug, "info": info}, WithLogger(logger))
+
+	status, _ := get(t, s, "section=debug")
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, out.String(), "Register ClusterCollector")
+	// The collectors and their requests log at the level of the section
+	assert.Contains(t, out.String(), "Cluster data collected for UUID")
+	assert.Contains(t, out.String(), "URL: ")
+	// The level of the section does not leak into the logger of the server
+	assert.Equal(t, log.InfoLevel, logger.GetLevel())
+
+	out.Reset()
+	status, _ = get(t, s, "section=info")
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, out.String(), "Section: info")
+	assert.NotContains(t, out.String(), "Register ClusterCollector")
+	assert.NotContains(t, out.String(), "Cluster data collected for UUID")
+	assert.NotContains(t, out.String(), "URL: ")
+}
+
+func TestHealthOnly(t *testing.T) {
+	fake, section := newPrism(t, fakeprism.DefaultConfig(), Cluster{})
+	s := New(map[string]Cluster{"health-section": section})
+
+	status, body := get(t, s, "health=true&section=health-section")
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "nutanix_exporter_TotalPollCycles_C")
+	assert.Contains(t, body, fmt.Sprintf("uuid=%q", fake.ClusterUUID()))
+	assert.Contains(t, body, fmt.Sprintf("section=%q", section.Host))
+	assert.NotContains(t, body, "nutanix_hosts_")
+
+	// The cluster UUID is looked up once
+	requests := fake.Requests()
+	status, _ = get(t, s, "health=true&section=health-section")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, requests, fake.Requests())
+}
+
+func TestAllSectionsHealth(t *testing.T) {
+	fake, section := newPrism(t, fakeprism.DefaultConfig(), Cluster{})
+	s := New(map[string]Cluster{
+		"cluster01": section,
+		"nohost":    {},
+	})
+
+	status, body := get(t, s, "health=true")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 2, countSeries(body, "nutanix_exporter_TotalPollCycles_C"))
+	assert.Contains(t, body, fmt.Sprintf(`cluster_uuid=%q,section=%q`, fake.ClusterUUID(), section.Host))
+	assert.Contains(t, body, fmt.Sprintf(`cluster_uuid=%q,section="nohost"`, HEALTH_UUID_FALLBACK))
+	// Health metrics only, no collection is run
+	assert.NotContains(t, body, "nutanix_vms_")
+	assert.Equal(t, uint64(1), fake.Requests())
+}
+
+func TestCollectionDuration(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(map[string]Cluster{}, WithClock(func() time.Time {
+		now = now.Add(2 * time.Second)
+		return now
+	}))
+
+	status, _ := get(t, s, "section=clock-test")
+	require.Equal(t, http.StatusNotFound, status)
+
+	status, body := get(t, s, "section=clock-test&health=true")
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `nutanix_exporter_TotalSuccessDeviceCollectionDuration_US{cluster_uuid="clock-test",section="clock-test",uuid="clock-test"} 2e+06`)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	SHUTDOWN_DEFAULT_GRACE_PERIOD = 20 * time.Second
+	// SHUTDOWN_CANCEL_TIMEOUT is the time given to the scrapes to return once
+	// their Prism requests are canceled
+	SHUTDOWN_CANCEL_TIMEOUT = 5 * time.Second
+)
+
+// Serve serves handler on l until ctx is done, then shuts down gracefully:
+// new connections are refused and in-flight scrapes may finish within the
+// grace period. The Prism requests of the scrapes still running after it are
+// canceled.
+func Serve(ctx context.Context, l net.Listener, handler http.Handler, grace time.Duration) error {
+	// Parent of the request contexts, canceled after the grace period
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv := &http.Server{
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return requestCtx },
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(l)
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Infof("Shutting down, waiting up to %s for in-flight scrapes", grace)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	log.Warnf("Scrapes still running after %s, canceling their Prism requests", grace)
+	cancelRequests()
+	cancelCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_CANCEL_TIMEOUT)
+	defer cancel()
+	if err := srv.Shutdown(cancelCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("scrapes not finished after canceling their Prism requests: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"nutanix-exporter/internal/fakeprism"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServe serves handler until the returned cancel func is called, the
+// error of Serve is sent to the returned channel
+func startServe(t *testing.T, handler http.Handler, grace time.Duration) (string, context.CancelFunc, <-chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, l, handler, grace)
+	}()
+	return "http://" + l.Addr().String(), cancel, done
+}
+
+func TestServeDrainsInFlightScrapes(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("complete"))
+	})
+	url, shutdown, done := startServe(t, handler, 5*time.Second)
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{body: string(body), err: err}
+	}()
+	<-started
+	shutdown()
+
+	res := <-results
+	require.NoError(t, res.err)
+	assert.Equal(t, "complete", res.body)
+	assert.NoError(t, <-done)
+
+	// New connections are refused
+	_, err := http.Get(url)
+	assert.Error(t, err)
+}
+
+func TestServeCancelsScrapesAfterGracePeriod(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(canceled)
+	})
+	url, shutdown, done := startServe(t, handler, 100*time.Millisecond)
+
+	go func() {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	shutdown()
+
+	assert.NoError(t, <-done)
+	select {
+	case <-canceled:
+	default:
+		t.Fatal("scrape was not canceled")
+	}
+}
+
+func TestServeCancelsPrismRequests(t *testing.T) {
+	conf := fakeprism.DefaultConfig()
+	conf.Latency = 10 * time.Second
+	fake, section := newPrism(t, conf, Cluster{Collect: map[string]bool{"cluster": true}})
+	url, shutdown, done := startServe(t, New(map[string]Cluster{"slow": section}), 100*time.Millisecond)
+
+	go func() {
+		if resp, err := http.Get(url + "?section=slow"); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	require.Eventually(t, func() bool { return fake.Requests() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	start := time.Now()
+	shutdown()
+	assert.NoError(t, <-done)
+	assert.Less(t, time.Since(start), conf.Latency)
+}
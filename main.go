@@ -11,44 +11,35 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
-	"nutanix-exporter/internal/nutanix"
+	"nutanix-exporter/internal/server"
 	"os"
-	"strings"
-	"sync"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
-	yaml "gopkg.in/yaml.v2"
 )
 
 var (
-	namespace       = "nutanix"
-	nutanixURL      = flag.String("nutanix.url", "", "Nutanix URL to connect to API https://nutanix.local.host:9440")
-	nutanixUser     = flag.String("nutanix.username", "<no value>", "Nutanix API User")
-	nutanixPassword = flag.String("nutanix.password", "<no value>", "Nutanix API User Password")
-	listenAddress   = flag.String("listen-address", ":9405", "The address to lisiten on for HTTP requests.")
-	nutanixConfig   = flag.String("nutanix.conf", "", "Which Nutanixconf.yml file should be used")
-
-	configModTime        time.Time    = time.Time{}
-	configFileWasMissing              = false
-	clusterUUIDCache                  = make(map[string]string) // Cache cluster UUID per section
-	clusterUUIDCacheMu   sync.RWMutex                           // Mutex for thread-safe cache access
+	namespace           = "nutanix"
+	nutanixURL          = flag.String("nutanix.url", "", "Nutanix URL to connect to API https://nutanix.local.host:9440")
+	nutanixUser         = flag.String("nutanix.username", "<no value>", "Nutanix API User")
+	nutanixPassword     = flag.String("nutanix.password", "<no value>", "Nutanix API User Password")
+	listenAddress       = flag.String("listen-address", ":9405", "The address to lisiten on for HTTP requests.")
+	nutanixConfig       = flag.String("nutanix.conf", "", "Which Nutanixconf.yml file should be used")
+	shutdownGracePeriod = flag.Duration("shutdown-grace-period", server.SHUTDOWN_DEFAULT_GRACE_PERIOD, "Time given to in-flight scrapes to finish on shutdown, their Prism requests are canceled after it")
+
+	configModTime        time.Time = time.Time{}
+	configFileWasMissing           = false
+	errConfigChanged               = errors.New("config file changed")
 )
 
-type cluster struct {
-	Host                string          `yaml:"nutanix_host"`
-	Username            string          `yaml:"nutanix_user"`
-	Password            string          `yaml:"nutanix_password"`
-	LogLevel            string          `yaml:"log_level"`
-	MaxParallelRequests int             `yaml:"max_parallel_requests"`
-	Collect             map[string]bool `yaml:"collect"`
-}
-
 // type clusterCollect struct {
 // 	Vms               string `yaml:"vms"`
 // 	Cluster           string `yaml:"cluster"`
@@ -57,13 +48,27 @@ type cluster struct {
 // }
 
 func main() {
-	// add config file watch
-	go monitorConfigFileChange()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backfill":
+			os.Exit(runBackfill(os.Args[2:]))
+		case "collect":
+			os.Exit(runCollect(os.Args[2:], os.Stdout))
+		}
+	}
 
+	flag.Usage = usage
 	flag.Parse()
 
+	// Shut down on SIGTERM/SIGINT, and to restart when the config file changes
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	ctx, restart := context.WithCancelCause(ctx)
+
+	// add config file watch
+	go monitorConfigFileChange(restart)
+
 	//Use locale configfile
-	var config map[string]cluster
 	var file []byte = nil
 	var err error
 
@@ -82,250 +87,14 @@ func main() {
 	}
 
 	log.Debugf("Config File readed")
-	err = yaml.Unmarshal(file, &config)
+	config, err := server.ParseConfig(file)
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.Debug("Config file unmarshalled")
 
 	//	http.Handle("/metrics", prometheus.Handler())
-	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
-		params := r.URL.Query()
-		sectionParam := params.Get("section")
-		healthOnly := strings.EqualFold(params.Get("health"), "true")
-
-		registry := prometheus.NewRegistry()
-
-		// If section is not provided, default to "default" section
-		if len(sectionParam) == 0 {
-			// If health=true with no section, collect health metrics for all sections only
-			if healthOnly {
-				log.Infof("health=true with no section specified, collecting health metrics for all configured sections")
-				// Iterate through all sections in config
-				for sectionName, conf := range config {
-					healthSectionKey := conf.Host
-					if len(healthSectionKey) == 0 {
-						healthSectionKey = sectionName // Fallback to section name if host is empty
-					}
-
-					// Get cluster UUID for this section (from cache if available)
-					healthUUID := "exporter-health"
-					clusterUUID := "exporter-health"
-
-					// Try to get from cache first
-					clusterUUIDCacheMu.RLock()
-					cachedUUID, found := clusterUUIDCache[sectionName]
-					clusterUUIDCacheMu.RUnlock()
-
-					if found {
-						healthUUID = cachedUUID
-						clusterUUID = cachedUUID
-					} else if len(conf.Host) > 0 {
-						// Try to fetch cluster UUID if host is configured
-						// Use a temporary API client just for UUID lookup
-						tempAPI := nutanix.NewNutanix(conf.Host, conf.Username, conf.Password, conf.MaxParallelRequests)
-						clusterUUIDValue, err := tempAPI.GetClusterUUID()
-						if err != nil {
-							log.Debugf("Failed to get cluster UUID for section %s: %v, using fallback", sectionName, err)
-							healthUUID = sectionName
-							clusterUUID = sectionName
-						} else {
-							healthUUID = clusterUUIDValue
-							clusterUUID = clusterUUIDValue
-							// Cache it for future requests
-							clusterUUIDCacheMu.Lock()
-							clusterUUIDCache[sectionName] = clusterUUIDValue
-							clusterUUIDCacheMu.Unlock()
-						}
-					}
-
-					// Register health collector for this section
-					registry.MustRegister(nutanix.NewExporterHealthCollector(healthSectionKey, healthUUID, clusterUUID))
-				}
-
-				// For all-sections health mode, only return health metrics (not regular metrics)
-				h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
-				h.ServeHTTP(w, r)
-				return
-			}
-
-			// No section and no health=true: default to "default" section (original behavior)
-			// This preserves backward compatibility - returns only regular metrics for default section
-			sectionParam = "default"
-		}
-
-		// Single section mode - original behavior
-		section := sectionParam
-		collStart := time.Now()
-
-		log.Infof("Section: %s", section)
-		log.Debug("Create Nutanix instance")
-
-		var collecthostnics bool = false
-		var collectvmnics bool = false
-		var maxParallelReq int = 0
-		// Section is always provided as host IP (e.g., "10.20.10.40") and should match config key
-		conf, ok := config[section]
-		var healthSectionKey string // Key used for health tracking - must match what nutanix.go uses
-
-		if ok {
-			switch strings.ToLower(conf.LogLevel) {
-			case "debug":
-				log.SetLevel(log.DebugLevel)
-			case "trace":
-				log.SetLevel(log.TraceLevel)
-			default:
-				log.SetLevel(log.InfoLevel)
-			}
-			*nutanixURL = conf.Host
-			// Use host URL as-is for health section key (must match what nutanix.go uses in g.url)
-			// nutanix.go uses g.url directly (e.g., "https://10.20.10.40:9440") for health tracking
-			// Since section = host IP and conf.Host = full URL, this ensures health tracking matches API calls
-			healthSectionKey = conf.Host
-			*nutanixUser = conf.Username
-			*nutanixPassword = conf.Password
-			maxParallelReq = conf.MaxParallelRequests
-			if hostnicsValue, exists := conf.Collect["hostnics"]; exists {
-				collecthostnics = hostnicsValue
-			}
-			if vmnicsValue, exists := conf.Collect["vmnics"]; exists {
-				collectvmnics = vmnicsValue
-			}
-		} else {
-			// Section not found
-			healthSectionKey = section
-			if !healthOnly {
-				log.Warnf("Section '%s' not found in config file", section)
-			}
-		}
-
-		// Always track collection cycles (for both regular and health metrics)
-		// This ensures health metrics are updated even when collecting regular metrics
-		// Health metrics are only exposed when health=true is explicitly requested
-		started := nutanix.MarkCollectionStart(healthSectionKey)
-		if !started {
-			// Collection already running, return early without tracking end
-			// (MarkCollectionEnd should only be called for collections that actually started)
-			return
-		}
-
-		// Track collection success - starts as true, set to false on errors
-		collectionSuccess := true
-		defer func() {
-			nutanix.MarkCollectionEnd(healthSectionKey, collectionSuccess, time.Since(collStart))
-		}()
-
-		// Get cluster UUID for health metrics (needed for proper association)
-		healthUUID := "exporter-health"  // Default fallback (used as uuid)
-		clusterUUID := "exporter-health" // Default fallback (used as cluster_uuid)
-		var nutanixAPI *nutanix.Nutanix
-
-		if healthOnly {
-			// For health-only requests, try to get cluster UUID
-			if ok {
-				// Check cache first (thread-safe)
-				clusterUUIDCacheMu.RLock()
-				cachedUUID, found := clusterUUIDCache[section]
-				clusterUUIDCacheMu.RUnlock()
-
-				if found {
-					healthUUID = cachedUUID
-					clusterUUID = cachedUUID
-					log.Debugf("Using cached cluster UUID for section %s: %s", section, healthUUID)
-				} else if len(conf.Host) > 0 {
-					// Create Nutanix API client and try to get cluster UUID
-					log.Infof("Host: %s", *nutanixURL)
-					tempAPI := nutanix.NewNutanix(*nutanixURL, *nutanixUser, *nutanixPassword, maxParallelReq)
-					clusterUUIDValue, err := tempAPI.GetClusterUUID()
-					if err != nil {
-						log.Debugf("Failed to get cluster UUID for health metrics: %v, using section name as fallback", err)
-						healthUUID = section // Fallback to section name
-						clusterUUID = section
-					} else {
-						healthUUID = clusterUUIDValue
-						clusterUUID = clusterUUIDValue
-						// Cache it for future requests (thread-safe)
-						clusterUUIDCacheMu.Lock()
-						clusterUUIDCache[section] = clusterUUIDValue
-						clusterUUIDCacheMu.Unlock()
-						log.Infof("Successfully fetched and cached cluster UUID for section %s: %s", section, healthUUID)
-					}
-				}
-			} else {
-				// Config section not found, use section name as fallback
-				healthUUID = section
-				clusterUUID = section
-			}
-
-			// Register health collector only when health=true is explicitly requested
-			registry.MustRegister(nutanix.NewExporterHealthCollector(healthSectionKey, healthUUID, clusterUUID))
-			h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
-			h.ServeHTTP(w, r)
-			return
-		}
-
-		// Regular metrics collection (healthOnly is false)
-		// Health metrics are tracked but not exposed - only register regular collectors
-
-		// Ensure Nutanix API client is created
-		if nutanixAPI == nil {
-			if !ok || *nutanixURL == "" {
-				// Section not found or missing config
-				log.Errorf("Cannot create Nutanix API client: missing configuration for section '%s'", section)
-				http.Error(w, fmt.Sprintf("Section '%s' not found in config", section), http.StatusNotFound)
-				return
-			}
-			nutanixAPI = nutanix.NewNutanix(*nutanixURL, *nutanixUser, *nutanixPassword, maxParallelReq)
-		}
-
-		// Poll cycles are tracked automatically when MarkCollectionEnd is called
-		// No ticker needed - each scrape from Prometheus receiver = one poll cycle
-
-		// Only register other collectors if section is found and not health-only
-		if !healthOnly && ok {
-			checkCollect := func(c map[string]bool, f string) bool {
-				val, exist := c[f]
-				return !exist || (exist && val)
-			}
-
-			if checkCollect(config[section].Collect, "storage_containers") {
-				log.Debugf("Register StorageContainersCollector")
-				registry.MustRegister(nutanix.NewStorageContainersCollector(nutanixAPI))
-			}
-			if checkCollect(config[section].Collect, "hosts") {
-				log.Debugf("Register HostsCollector")
-				registry.MustRegister(nutanix.NewHostsCollector(nutanixAPI, collecthostnics))
-			}
-			if checkCollect(config[section].Collect, "cluster") {
-				log.Debugf("Register ClusterCollector")
-				registry.MustRegister(nutanix.NewClusterCollector(nutanixAPI))
-			}
-			if checkCollect(config[section].Collect, "vms") {
-				log.Debugf("Register VmsCollector")
-				registry.MustRegister(nutanix.NewVmsCollector(nutanixAPI, collectvmnics))
-			}
-			if checkCollect(config[section].Collect, "snapshots") {
-				log.Debugf("Register Snapshots")
-				registry.MustRegister(nutanix.NewSnapshotsCollector(nutanixAPI))
-			}
-			if checkCollect(config[section].Collect, "virtual_disks") {
-				log.Debugf("Register VirtualDisksCollector")
-				registry.MustRegister(nutanix.NewVirtualDisksCollector(nutanixAPI))
-			}
-		}
-
-		h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
-		// Track if HTTP response writing fails
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					collectionSuccess = false
-					log.Errorf("Panic while serving metrics: %v", r)
-				}
-			}()
-			h.ServeHTTP(w, r)
-		}()
-	})
+	http.Handle("/metrics", server.New(config))
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
@@ -338,13 +107,33 @@ func main() {
 	})
 
 	log.Infof("Starting Server: %s", *listenAddress)
-	err = http.ListenAndServe(*listenAddress, nil)
+	listener, err := net.Listen("tcp", *listenAddress)
 	if err != nil {
 		log.Fatal(err)
 	}
+	exitCode := 0
+	if err := server.Serve(ctx, listener, http.DefaultServeMux, *shutdownGracePeriod); err != nil {
+		log.Error(err)
+		exitCode = 1
+	}
+	if errors.Is(context.Cause(ctx), errConfigChanged) {
+		// Exit non-zero to be restarted with the new config
+		exitCode = 1
+	}
+	log.Info("Exporter stopped")
+	flushLogs()
+	os.Exit(exitCode)
+}
+
+// flushLogs syncs the log output to disk, if it is a file
+func flushLogs() {
+	if f, ok := log.StandardLogger().Out.(*os.File); ok {
+		f.Sync()
+	}
 }
 
-func monitorConfigFileChange() {
+// monitorConfigFileChange calls restart when the config file changes
+func monitorConfigFileChange(restart context.CancelCauseFunc) {
 	for {
 		select {
 		case <-time.After(time.Minute):
@@ -356,7 +145,8 @@ func monitorConfigFileChange() {
 				modTime := fileInfo.ModTime()
 				if configFileWasMissing || (!configModTime.IsZero() && configModTime != modTime) {
 					log.Infof("Config %v file has changed. Restarting exporter...\n", *nutanixConfig)
-					os.Exit(1)
+					restart(errConfigChanged)
+					return
 				}
 				configModTime = modTime
 			}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"net/http"
-	"net/http/httptest"
+	"nutanix-exporter/internal/server"
 	"testing"
 	"time"
 
@@ -10,52 +10,6 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestHealthOnlyEndpoint(t *testing.T) {
-	// Create a test server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Simulate the /metrics handler with health=true
-		params := r.URL.Query()
-		section := params.Get("section")
-		if len(section) == 0 {
-			section = "default"
-		}
-
-		healthOnly := params.Get("health") == "true"
-
-		// Mock config (simplified for test)
-
-		// Simulate health-only request
-		if healthOnly {
-			// Should only return health metrics, no Nutanix API calls
-			w.Header().Set("Content-Type", "text/plain")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("# HELP nutanix_exporter_TotalPollCycles_C Exporter: total poll cycles\n# TYPE nutanix_exporter_TotalPollCycles_C counter\nnutanix_exporter_TotalPollCycles_C{uuid=\"exporter-health\",section=\"test-section\"} 0\n"))
-			return
-		}
-
-		// Regular request would return more metrics
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("regular metrics"))
-	}))
-	defer server.Close()
-
-	// Test health-only request
-	resp, err := http.Get(server.URL + "/metrics?health=true&section=test-section")
-	require.NoError(t, err)
-	defer resp.Body.Close()
-
-	assert.Equal(t, http.StatusOK, resp.StatusCode)
-
-	// Verify response contains health metrics
-	body := make([]byte, 1024)
-	n, _ := resp.Body.Read(body)
-	response := string(body[:n])
-
-	assert.Contains(t, response, "nutanix_exporter_TotalPollCycles_C")
-	assert.Contains(t, response, "uuid=\"exporter-health\"")
-	assert.Contains(t, response, "section=\"test-section\"")
-}
-
 func TestSectionHandling(t *testing.T) {
 	// Test that section parameter is properly handled
 	req, err := http.NewRequest("GET", "/metrics?section=my-section", nil)
@@ -95,36 +49,9 @@ func TestHealthUUIDGeneration(t *testing.T) {
 	assert.Equal(t, "test-section", healthUUID)
 }
 
-func TestStartedTickersTracking(t *testing.T) {
-	// Reset global state
-	startedTickers = make(map[string]bool)
-
-	section1 := "section1"
-	section2 := "section2"
-
-	// First call should start ticker
-	if !startedTickers[section1] {
-		startedTickers[section1] = true
-	}
-	assert.True(t, startedTickers[section1])
-	assert.False(t, startedTickers[section2])
-
-	// Second call should not start ticker again
-	if !startedTickers[section1] {
-		startedTickers[section1] = true
-	}
-	assert.True(t, startedTickers[section1])
-
-	// Different section should start its own ticker
-	if !startedTickers[section2] {
-		startedTickers[section2] = true
-	}
-	assert.True(t, startedTickers[section2])
-}
-
 func TestConfigValidation(t *testing.T) {
 	// Test config structure
-	config := map[string]cluster{
+	config := map[string]server.Cluster{
 		"test-cluster": {
 			Host:                "10.20.10.40",
 			Username:            "admin",
@@ -154,7 +81,7 @@ func TestConfigValidation(t *testing.T) {
 
 func TestCollectionIntervalLogic(t *testing.T) {
 	// Test collection interval calculation
-	conf := cluster{
+	conf := server.Cluster{
 		MaxParallelRequests: 5,
 	}
 
@@ -166,7 +93,7 @@ func TestCollectionIntervalLogic(t *testing.T) {
 	assert.Equal(t, 30, collectionInterval)
 
 	// Test with zero parallel requests
-	conf2 := cluster{
+	conf2 := server.Cluster{
 		MaxParallelRequests: 0,
 	}
 